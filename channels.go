@@ -0,0 +1,165 @@
+package gotirc
+
+import (
+	"context"
+	"sort"
+)
+
+// OnSelfJoin adds an event callback for when the server confirms that the client
+// joined a channel
+func (c *Client) OnSelfJoin(callback func(channel string)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.selfJoinCallbacks = append(c.selfJoinCallbacks, callback)
+}
+
+// OnSelfPart adds an event callback for when the client leaves a channel, either
+// because the server confirmed a PART or because it reported that the channel
+// was suspended
+func (c *Client) OnSelfPart(callback func(channel string)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.selfPartCallbacks = append(c.selfPartCallbacks, callback)
+}
+
+// Channels returns the channels the client is in, sorted alphabetically. A
+// channel is added when the server echoes the client's JOIN and removed when it
+// echoes the client's PART. The list is cleared when the client connects again.
+func (c *Client) Channels() []string {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	channels := make([]string, 0, len(c.joined))
+	for channel := range c.joined {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// IsJoined returns true if the client is in a channel. If the "#" prefix is
+// missing, it is automatically prepended.
+func (c *Client) IsJoined(channel string) bool {
+	channel = channelName(channel)
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	_, ok := c.joined[channel]
+	return ok
+}
+
+// JoinAndWait joins a channel and waits for the outcome. It returns nil once the
+// server confirms the JOIN (or right away if the client is already in the
+// channel), a *NoticeError if the server rejects it (e.g., the channel is
+// suspended), ErrJoinTimeout if the server doesn't respond in time, the error
+// returned by Join if the JOIN could not be queued, ErrNotConnected if the
+// connection closes first, or ctx.Err() if ctx is done first.
+func (c *Client) JoinAndWait(ctx context.Context, channel string) error {
+	channel, err := validChannel(channel)
+	if err != nil {
+		return err
+	}
+
+	result := make(chan error, 1)
+	c.addJoinWaiter(channel, result)
+	defer c.removeJoinWaiter(channel, result)
+
+	if c.IsJoined(channel) {
+		return nil
+	}
+
+	c.connectedMu.RLock()
+	done := c.doneChan
+	c.connectedMu.RUnlock()
+
+	if err := c.Join(channel); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-result:
+		return err
+	case <-done:
+		return ErrNotConnected
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// addJoinWaiter adds a channel that receives the outcome of joining channel
+func (c *Client) addJoinWaiter(channel string, result chan error) {
+	c.joinsMu.Lock()
+	defer c.joinsMu.Unlock()
+	if c.joinWaiters == nil {
+		c.joinWaiters = make(map[string][]chan error)
+	}
+	c.joinWaiters[channel] = append(c.joinWaiters[channel], result)
+}
+
+func (c *Client) removeJoinWaiter(channel string, result chan error) {
+	c.joinsMu.Lock()
+	defer c.joinsMu.Unlock()
+	waiters := c.joinWaiters[channel]
+	for i := range waiters {
+		if waiters[i] == result {
+			c.joinWaiters[channel] = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(c.joinWaiters[channel]) == 0 {
+		delete(c.joinWaiters, channel)
+	}
+}
+
+// selfJoined records that the client is in a channel and runs the self join
+// callbacks if it wasn't already
+func (c *Client) selfJoined(channel string) {
+	c.stateMu.Lock()
+	if c.joined == nil {
+		c.joined = make(map[string]struct{})
+	}
+	_, ok := c.joined[channel]
+	c.joined[channel] = struct{}{}
+	c.stateMu.Unlock()
+
+	if ok {
+		return
+	}
+
+	c.callbackMu.Lock()
+	callbacks := c.selfJoinCallbacks
+	c.callbackMu.Unlock()
+
+	for _, cb := range callbacks {
+		cb(channel)
+	}
+}
+
+// selfParted records that the client is no longer in a channel and runs the
+// self part callbacks if it was in it
+func (c *Client) selfParted(channel string) {
+	c.readerParted(channel)
+
+	c.stateMu.Lock()
+	_, ok := c.joined[channel]
+	delete(c.joined, channel)
+	c.stateMu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	c.callbackMu.Lock()
+	callbacks := c.selfPartCallbacks
+	c.callbackMu.Unlock()
+
+	for _, cb := range callbacks {
+		cb(channel)
+	}
+}
+
+// resetJoined forgets the channels the client is in, e.g., when the read
+// connection reconnects
+func (c *Client) resetJoined() {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	c.joined = make(map[string]struct{})
+}
@@ -0,0 +1,125 @@
+package gotirc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChannels(t *testing.T) {
+	client := NewClient(Options{})
+	client.nick = "test_nick"
+	var joins, parts []string
+	client.OnSelfJoin(func(channel string) {
+		joins = append(joins, channel)
+	})
+	client.OnSelfPart(func(channel string) {
+		parts = append(parts, channel)
+	})
+
+	client.doCallbacks(":test_nick!test_nick@test_nick.tmi.twitch.tv JOIN #b\r\n")
+	client.doCallbacks(":test_nick!test_nick@test_nick.tmi.twitch.tv JOIN #a\r\n")
+	client.doCallbacks(":test_nick!test_nick@test_nick.tmi.twitch.tv JOIN #a\r\n")
+	client.doCallbacks(":other!other@other.tmi.twitch.tv JOIN #c\r\n")
+	if channels := client.Channels(); !reflect.DeepEqual(channels, []string{"#a", "#b"}) {
+		t.Errorf("Expected '[#a #b]', got '%v'", channels)
+	}
+	if !client.IsJoined("a") {
+		t.Error("Expected 'true', got 'false'")
+	}
+
+	client.doCallbacks(":other!other@other.tmi.twitch.tv PART #a\r\n")
+	client.doCallbacks(":test_nick!test_nick@test_nick.tmi.twitch.tv PART #b\r\n")
+	client.doCallbacks("@msg-id=msg_channel_suspended :tmi.twitch.tv NOTICE #a :This channel does not exist or has been suspended.\r\n")
+	if channels := client.Channels(); len(channels) != 0 {
+		t.Errorf("Expected '[]', got '%v'", channels)
+	}
+	if client.IsJoined("#a") {
+		t.Error("Expected 'false', got 'true'")
+	}
+
+	if !reflect.DeepEqual(joins, []string{"#b", "#a"}) {
+		t.Errorf("Expected '[#b #a]', got '%v'", joins)
+	}
+	if !reflect.DeepEqual(parts, []string{"#b", "#a"}) {
+		t.Errorf("Expected '[#b #a]', got '%v'", parts)
+	}
+}
+
+func TestJoinAndWait(t *testing.T) {
+	client := NewClient(Options{})
+	client.nick = "test_nick"
+	client.sendQueue = make(chan string, sendBufferSize)
+	client.state = StateConnected
+
+	// Confirmed
+	go func() {
+		<-client.sendQueue
+		client.doCallbacks(":test_nick!test_nick@test_nick.tmi.twitch.tv JOIN #test\r\n")
+	}()
+	if err := client.JoinAndWait(context.Background(), "test"); err != nil {
+		t.Errorf("Expected 'nil', got '%s'", err)
+	}
+
+	// Already joined
+	if err := client.JoinAndWait(context.Background(), "#test"); err != nil {
+		t.Errorf("Expected 'nil', got '%s'", err)
+	}
+	if n := len(client.sendQueue); n != 0 {
+		t.Errorf("Expected '0', got '%d'", n)
+	}
+
+	// Rejected
+	go func() {
+		<-client.sendQueue
+		client.doCallbacks("@msg-id=msg_channel_suspended :tmi.twitch.tv NOTICE #suspended :This channel does not exist or has been suspended.\r\n")
+	}()
+	err := client.JoinAndWait(context.Background(), "suspended")
+	if noticeErr, ok := err.(*NoticeError); !ok || noticeErr.MsgID != NoticeChannelSuspended {
+		t.Errorf("Expected '*NoticeError', got '%v'", err)
+	}
+
+	// Context cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := client.JoinAndWait(ctx, "silent"); err != context.DeadlineExceeded {
+		t.Errorf("Expected '%s', got '%v'", context.DeadlineExceeded, err)
+	}
+	<-client.sendQueue
+
+	// Not connected
+	client.state = StateDisconnected
+	if err := client.JoinAndWait(context.Background(), "other"); err != ErrNotConnected {
+		t.Errorf("Expected '%s', got '%v'", ErrNotConnected, err)
+	}
+}
+
+func TestJoinMixedCase(t *testing.T) {
+	client := NewClient(Options{})
+	client.nick = "test_nick"
+	client.sendQueue = make(chan string, sendBufferSize)
+	client.state = StateConnected
+
+	// The server refers to channels in lowercase
+	go func() {
+		data := <-client.sendQueue
+		if data != "JOIN #foo" {
+			t.Errorf("Expected 'JOIN #foo', got '%s'", data)
+		}
+		client.doCallbacks(":test_nick!test_nick@test_nick.tmi.twitch.tv JOIN #foo\r\n")
+		client.doCallbacks(":" + username + ".tmi.twitch.tv 353 " + username + " = #foo :test_nick user1\r\n")
+		client.doCallbacks(":" + username + ".tmi.twitch.tv 366 " + username + " #foo :End of /NAMES list\r\n")
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := client.JoinAndWait(ctx, "#Foo"); err != nil {
+		t.Errorf("Expected 'nil', got '%s'", err)
+	}
+	if !client.IsJoined("Foo") {
+		t.Error("Expected 'true', got 'false'")
+	}
+	if users := client.Users("#FOO"); !reflect.DeepEqual(users, []string{"test_nick", "user1"}) {
+		t.Errorf("Expected '[test_nick user1]', got '%v'", users)
+	}
+}
@@ -3,64 +3,223 @@ package gotirc
 
 import (
 	"bufio"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
 	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 )
 
 const sendBufferSize = 512
 
 var caps = []string{"membership", "commands", "tags"}
 
+var (
+	// ErrNotConnected is returned when sending while the client is not connected
+	ErrNotConnected = errors.New("not connected")
+	// ErrQueueFull is returned when sending while the send queue is full
+	ErrQueueFull = errors.New("send queue full")
+	// ErrInvalidChannel is returned when sending to an empty or malformed channel name
+	ErrInvalidChannel = errors.New("invalid channel")
+	// ErrInvalidUser is returned when whispering to an empty or malformed username
+	ErrInvalidUser = errors.New("invalid user")
+	// ErrShuttingDown is returned when sending while the client is shutting down
+	ErrShuttingDown = errors.New("shutting down")
+	// ErrInvalidTag is returned when sending a message with a malformed tag key
+	ErrInvalidTag = errors.New("invalid tag")
+	// ErrAuthenticationFailed is returned when connecting if the server rejects the
+	// nick or pass (oauth token)
+	ErrAuthenticationFailed = errors.New("authentication failed")
+)
+
 // Options facilitates passing desired settings to a new Client
 type Options struct {
 	Debug    bool
 	Port     int
 	Host     string
 	Channels []string
+
+	// RateLimit limits the messages sent in channels where the client is not a
+	// moderator. Defaults to RateLimitUser.
+	RateLimit RateLimit
+	// ModeratorRateLimit limits the messages sent in channels where USERSTATE
+	// reports that the client is a moderator or the broadcaster, and the total
+	// of all messages sent. Defaults to RateLimitModerator.
+	ModeratorRateLimit RateLimit
+	// JoinRateLimit limits the number of channels joined. JOINs are limited
+	// separately from other messages, and channels waiting to be joined are
+	// combined into a single JOIN. Defaults to JoinRateLimitUser.
+	JoinRateLimit RateLimit
+
+	// SplitLongMessages splits messages passed to Say and Whisper that are longer
+	// than Twitch's 500 character limit into several messages
+	SplitLongMessages bool
+	// NumberSplitMessages prefixes each part of a split message with its
+	// position, e.g., "(1/3) "
+	NumberSplitMessages bool
+
+	// AvoidDuplicates invisibly alters a message sent to a channel when it is
+	// identical to the previous message sent there, which Twitch would otherwise
+	// reject if sent within 30 seconds. The check is made when the message is
+	// written to the server, so time spent in the send queue is accounted for.
+	AvoidDuplicates bool
+
+	// SeparateReader makes the client read chat through a second, anonymous
+	// connection. Channels are joined by the read connection, while messages are
+	// sent by the authenticated connection, so that heavy reading never delays
+	// sending and a ban from sending never stops the client from reading.
+	SeparateReader bool
+
+	// AckTimeout is how long SayAndWait waits for the server to respond once the
+	// message is written to the server. Defaults to 10 seconds.
+	AckTimeout time.Duration
+
+	// Clock is used by the rate limiters instead of the time package, which
+	// allows tests to control the passage of time. Defaults to SystemClock.
+	Clock Clock
 }
 
 // Client holds state and context information to maintain a connection with a server
 type Client struct {
+	// Messages moved from sendQueue to the send loop's channel queues. Accessed
+	// atomically, so it is kept first for 64-bit alignment.
+	scheduled int64
+
 	options Options
 
 	sendQueue   chan string
+	sendMu      sync.Mutex
+	lastSentMu  sync.Mutex
+	lastSent    map[string]sentMessage
 	recvChannel chan Message
 	reader      *bufio.Reader
 	writer      *bufio.Writer
 
-	conn        net.Conn
-	readTimeout time.Duration
-	connectedMu sync.RWMutex
-	connected   bool
-	doneChan    chan struct{}
-
-	callbackMu            sync.Mutex
-	actionCallbacks       []func(channel string, tags map[string]string, msg string)
-	chatCallbacks         []func(channel string, tags map[string]string, msg string)
-	resubCallbacks        []func(channel string, tags map[string]string, msg string)
-	subGiftcallbacks      []func(channel string, tags map[string]string, msg string)
-	subscriptionCallbacks []func(channel string, tags map[string]string, msg string)
-	cheerCallbacks        []func(channel string, tags map[string]string, msg string)
-	joinCallbacks         []func(channel, username string)
-	partCallbacks         []func(channel, username string)
+	// Channels handed straight to the next send loop to join instead of being
+	// queued, so that any number of channels can be joined on connecting.
+	// Guarded by sendMu.
+	connectJoins []string
+
+	// The connection and its channels are replaced by each Connect, under
+	// connectedMu, and are only used without it by the send and receive loops,
+	// which have stopped before Connect returns
+	conn         net.Conn
+	readTimeout  time.Duration
+	connectedMu  sync.RWMutex
+	state        State
+	shuttingDown bool
+
+	// State changes waiting for their callbacks to run, see deliverStateChanges
+	stateChanges     []State
+	deliveringStates bool
+	doneChan         chan struct{}
+
+	// Closed when Shutdown is called, and when the send loop has sent the queued
+	// messages during a shutdown
+	shutdownChan chan struct{}
+	flushedChan  chan struct{}
+
+	callbackMu                   sync.Mutex
+	actionCallbacks              []func(channel string, tags map[string]string, msg string)
+	chatCallbacks                []func(channel string, tags map[string]string, msg string)
+	resubCallbacks               []func(channel string, tags map[string]string, msg string)
+	subGiftcallbacks             []func(channel string, tags map[string]string, msg string)
+	subscriptionCallbacks        []func(channel string, tags map[string]string, msg string)
+	cheerCallbacks               []func(channel string, tags map[string]string, msg string)
+	whisperCallbacks             []func(from string, tags map[string]string, msg string)
+	whisperEventCallbacks        []func(whisper WhisperEvent)
+	raidCallbacks                []func(channel, raider string, viewers int, tags map[string]string)
+	unraidCallbacks              []func(channel string, tags map[string]string)
+	ritualCallbacks              []func(channel string, tags map[string]string, msg string)
+	announcementCallbacks        []func(channel, color string, tags map[string]string, msg string)
+	bitsBadgeTierCallbacks       []func(channel string, threshold int, tags map[string]string, msg string)
+	viewerMilestoneCallbacks     []func(channel string, tags map[string]string, msg string)
+	userNoticeCallbacks          []func(channel, msgID string, tags map[string]string, msg string)
+	mysteryGiftCallbacks         []func(channel string, count int, tags map[string]string)
+	anonSubGiftCallbacks         []func(channel string, tags map[string]string, msg string)
+	giftPaidUpgradeCallbacks     []func(channel string, tags map[string]string, msg string)
+	anonGiftPaidUpgradeCallbacks []func(channel string, tags map[string]string, msg string)
+	primePaidUpgradeCallbacks    []func(channel string, tags map[string]string, msg string)
+	giftBombCallbacks            []func(channel string, bomb GiftBombEvent)
+	hostCallbacks                []func(channel, target string, viewers int)
+	unhostCallbacks              []func(channel string)
+	namesCallbacks               []func(channel string, users []string)
+	modeCallbacks                []func(channel, username string, mod bool)
+	droppedCallbacks             []func(msg string, err error)
+	joinResultCallbacks          []func(channel string, err error)
+	joinCallbacks                []func(channel, username string)
+	partCallbacks                []func(channel, username string)
+	roomStateCallbacks           []func(channel string, state RoomState, changed map[string]string)
+	userStateCallbacks           []func(channel string, state UserState)
+	selfModCallbacks             []func(channel string, mod bool)
+	noticeCallbacks              []func(channel string, msgID NoticeID, text string)
+	stateChangeCallbacks         []func(state State)
+	selfJoinCallbacks            []func(channel string)
+	selfPartCallbacks            []func(channel string)
+
+	stateMu         sync.RWMutex
+	nick            string
+	roomStates      map[string]RoomState
+	globalUserState UserState
+	userStates      map[string]UserState
+	hostTargets     map[string]string
+	rosters         map[string]map[string]struct{}
+	pendingNames    map[string]map[string]struct{}
+	moderators      map[string]map[string]struct{}
+	vips            map[string]map[string]struct{}
+	joined          map[string]struct{}
+
+	pendingSays pendingSays
+	ackTimeout  time.Duration
+
+	joinsMu      sync.Mutex
+	pendingJoins map[string]*pendingJoin
+	joinWaiters  map[string][]chan error
+	joinTimeout  time.Duration
+
+	giftBombMu      sync.Mutex
+	giftBombs       map[string]*pendingGiftBomb
+	giftBombTimeout time.Duration
+
+	// The anonymous read connection used when Options.SeparateReader is set, or
+	// for the read connection itself, the Client that owns it
+	readClient           *Client
+	parent               *Client
+	readerMu             sync.Mutex
+	readerChannels       map[string]bool // Whether a JOIN was queued on the current read connection
+	readerStopped        bool
+	readerReconnectDelay time.Duration
 }
 
 // NewClient returns a new Client
 func NewClient(o Options) *Client {
-	return &Client{
-		options:     o,
-		readTimeout: 10 * time.Minute,
+	c := &Client{
+		options:              o,
+		readTimeout:          10 * time.Minute,
+		giftBombTimeout:      10 * time.Second,
+		ackTimeout:           10 * time.Second,
+		joinTimeout:          10 * time.Second,
+		readerReconnectDelay: 5 * time.Second,
+	}
+	if o.AckTimeout > 0 {
+		c.ackTimeout = o.AckTimeout
 	}
+	if o.SeparateReader {
+		c.readClient = c.newReader()
+	}
+	return c
 }
 
 // Connect connects the client to the server specified in the options and uses
 // the supplied nick and pass (oauth token) to authenticate. Connect blocks and
-// runs event callbacks until disconnected
+// runs event callbacks until disconnected. If the server rejects the nick or
+// pass, Connect returns ErrAuthenticationFailed.
 func (c *Client) Connect(nick string, pass string) error {
 	conn, err := c.doConnect(func() (net.Conn, error) {
 		return net.Dial("tcp", fmt.Sprintf("%s:%d", c.options.Host, c.options.Port))
@@ -70,74 +229,206 @@ func (c *Client) Connect(nick string, pass string) error {
 		return err
 	}
 
-	return c.doPostConnect(nick, pass, conn, 19, 30)
+	if c.readClient != nil {
+		stop := c.startReader()
+		defer stop()
+	}
+
+	limit, _ := c.rateLimits()
+	return c.doPostConnect(nick, pass, conn, float64(limit.Messages), limit.Per.Seconds())
 }
 
 func (c *Client) doConnect(connFactory func() (net.Conn, error)) (net.Conn, error) {
-	c.connectedMu.Lock()
-	defer c.connectedMu.Unlock()
-
-	if c.connected {
+	if !c.setState(StateConnecting, StateDisconnected) {
 		return nil, errors.New("Already connected")
 	}
 
 	conn, err := connFactory()
-	if err == nil {
-		c.connected = true
+	if err != nil {
+		c.setState(StateDisconnected)
+		return nil, err
 	}
-
-	return conn, err
+	return conn, nil
 }
 
 // Disconnect closes the client's connection with the server
 func (c *Client) Disconnect() {
 	c.connectedMu.Lock()
-	defer c.connectedMu.Unlock()
-	if c.connected {
-		c.connected = false
-		close(c.doneChan)
+	switch c.state {
+	case StateDisconnected:
+		c.connectedMu.Unlock()
+		return
+	case StateConnecting:
+		// doPostConnect closes the connection once it is established
+	default:
+		if !isClosed(c.doneChan) {
+			close(c.doneChan)
+			c.conn.Close()
+		}
 	}
+	c.changeState(StateClosing)
+	c.connectedMu.Unlock()
+
+	c.discardPendingJoins()
+	c.discardGiftBombs()
+	c.deliverStateChanges()
 }
 
 // Connected returns true if the client is currently connected to the server,
 // false otherwise
 func (c *Client) Connected() bool {
-	c.connectedMu.RLock()
-	defer c.connectedMu.RUnlock()
-	return c.connected
+	return c.State() == StateConnected
 }
 
 func (c *Client) doPostConnect(nick, pass string, conn net.Conn, maxMessages, perSeconds float64) error {
+	defer c.setState(StateDisconnected)
+
+	c.sendMu.Lock()
+	c.sendQueue = make(chan string, sendBufferSize)
+	atomic.StoreInt64(&c.scheduled, 0)
+	c.sendMu.Unlock()
+
+	c.connectedMu.Lock()
 	c.conn = conn
 	c.reader = bufio.NewReader(conn)
 	c.writer = bufio.NewWriter(conn)
 	c.doneChan = make(chan struct{})
-	c.sendQueue = make(chan string, sendBufferSize)
-	defer close(c.sendQueue)
+	c.shutdownChan = make(chan struct{})
+	c.flushedChan = make(chan struct{})
+	c.shuttingDown = false
+	c.connectedMu.Unlock()
+
+	c.discardPendingJoins()
+	c.discardGiftBombs()
+	c.pendingSays.forgetJoins()
+
+	if !c.setState(StateAuthenticating, StateConnecting) {
+		// Disconnected while connecting
+		conn.Close()
+		return ErrNotConnected
+	}
+
+	c.stateMu.Lock()
+	c.nick = strings.ToLower(nick)
+	c.roomStates = make(map[string]RoomState)
+	c.globalUserState = UserState{}
+	c.userStates = make(map[string]UserState)
+	c.hostTargets = make(map[string]string)
+	c.rosters = make(map[string]map[string]struct{})
+	c.pendingNames = make(map[string]map[string]struct{})
+	c.moderators = make(map[string]map[string]struct{})
+	c.vips = make(map[string]map[string]struct{})
+	c.joined = make(map[string]struct{})
+	c.stateMu.Unlock()
 
 	if err := c.authenticate(nick, pass); err != nil {
+		c.Disconnect()
 		return err
 	}
+	if !c.Connected() {
+		// Disconnected while authenticating
+		return ErrNotConnected
+	}
 
+	// The channels in the options are handed straight to the send loop, since
+	// queuing a JOIN for each of them could overflow the send queue. A read
+	// connection joins the channels of the client that owns it.
+	var joins []string
 	for _, channel := range c.options.Channels {
-		c.Join(channel)
+		name, err := validChannel(channel)
+		if err != nil {
+			c.doJoinResultCallbacks(channel, err)
+			continue
+		}
+		joins = append(joins, name)
+	}
+	if c.readClient != nil && len(joins) > 0 {
+		if err := c.joinReader(joins...); err != nil {
+			for _, channel := range joins {
+				c.doJoinResultCallbacks(channel, err)
+			}
+		}
+		joins = nil
+	}
+	if c.parent != nil {
+		joins = c.parent.readerJoins()
 	}
+	c.sendMu.Lock()
+	c.connectJoins = joins
+	c.sendMu.Unlock()
+
+	sendLoopDone := make(chan struct{})
+	go func() {
+		defer close(sendLoopDone)
+		c.startSendLoop(maxMessages, perSeconds)
+	}()
+	err := c.startRecvLoop()
+	<-sendLoopDone
+	return err
+}
 
-	go c.startSendLoop(maxMessages, perSeconds)
-	return c.startRecvLoop()
+// Say sends a message to a channel. If the "#" prefix is missing, it is
+// automatically prepended.
+func (c *Client) Say(channel string, msg string) error {
+	channel, err := validChannel(channel)
+	if err != nil {
+		return err
+	}
+	return c.say(channel, "", msg)
 }
 
-// Say sends a message to a channel
-func (c *Client) Say(channel string, msg string) {
-	if channel[0] != '#' {
-		channel = "#" + channel
+// say sends msg to a channel with the given command prefix (e.g., "/w user "),
+// splitting it if SplitLongMessages is set. The parts are queued contiguously.
+func (c *Client) say(channel, prefix, msg string) error {
+	parts := []string{msg}
+	if c.options.SplitLongMessages {
+		limit := maxMessageLength - utf8.RuneCountInString(prefix)
+		if c.options.AvoidDuplicates && prefix == "" {
+			// Leave room for the spacer appended to a duplicate
+			limit -= utf8.RuneCountInString(duplicateSpacer)
+		}
+		parts = splitMessage(msg, limit, c.options.NumberSplitMessages)
+	}
+
+	for i := range parts {
+		parts[i] = fmt.Sprintf("PRIVMSG %s :%s%s", channel, prefix, parts[i])
 	}
-	c.send(fmt.Sprintf("PRIVMSG %s :%s", channel, msg))
+	return c.sendAll(parts...)
+}
+
+// SayWithTags sends a message prefixed with IRCv3 tags to a channel and returns
+// the client-nonce of the message. Unless tags already contains a client-nonce,
+// a random one is added so that the USERSTATE or NOTICE sent by the server in
+// response can be matched to the message. It returns ErrInvalidTag if a key of
+// tags is not a valid IRCv3 tag key.
+func (c *Client) SayWithTags(channel, msg string, tags map[string]string) (string, error) {
+	channel, err := validChannel(channel)
+	if err != nil {
+		return "", err
+	}
+	for k := range tags {
+		if !validTagKey(k) {
+			return "", ErrInvalidTag
+		}
+	}
+
+	t := make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		t[k] = v
+	}
+	if t["client-nonce"] == "" {
+		t["client-nonce"] = newNonce()
+	}
+
+	return t["client-nonce"], c.sendWithTags(t, "PRIVMSG %s :%s", channel, msg)
 }
 
 // Whisper sends a whisper to a user
-func (c *Client) Whisper(user string, msg string) {
-	c.Say("#jtv", "/w "+user+" "+msg)
+func (c *Client) Whisper(user string, msg string) error {
+	if user == "" || strings.ContainsAny(user, " \r\n") {
+		return ErrInvalidUser
+	}
+	return c.say("#jtv", "/w "+user+" ", msg)
 }
 
 // OnAction adds an event callback for action (e.g., /me) messages
@@ -182,6 +473,13 @@ func (c *Client) OnCheer(callback func(channel string, tags map[string]string, m
 	c.cheerCallbacks = append(c.cheerCallbacks, callback)
 }
 
+// OnWhisper adds an event callback for when a user whispers the client
+func (c *Client) OnWhisper(callback func(from string, tags map[string]string, msg string)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.whisperCallbacks = append(c.whisperCallbacks, callback)
+}
+
 // OnJoin adds an event callback for when a user joins a channel
 func (c *Client) OnJoin(callback func(channel, username string)) {
 	c.callbackMu.Lock()
@@ -198,20 +496,64 @@ func (c *Client) OnPart(callback func(channel, username string)) {
 
 // Join tells the client to join a particular channel. If the "#" prefix is missing,
 // it is automatically prepended.
-func (c *Client) Join(channel string) {
-	if !strings.HasPrefix(channel, "#") {
-		channel = "#" + channel
+func (c *Client) Join(channel string) error {
+	channel, err := validChannel(channel)
+	if err != nil {
+		return err
+	}
+	if c.readClient != nil {
+		return c.joinReader(channel)
 	}
-	c.send("JOIN %s", channel)
+	return c.send("JOIN %s", channel)
 }
 
 // Part tells the client to part a particular channel. If the "#" prefix is missing,
 // it is automatically prepended.
-func (c *Client) Part(channel string) {
+func (c *Client) Part(channel string) error {
+	channel, err := validChannel(channel)
+	if err != nil {
+		return err
+	}
+	if c.readClient != nil {
+		return c.partReader(channel)
+	}
+	return c.send("PART %s", channel)
+}
+
+// OnDropped adds an event callback for when a message is discarded instead of
+// being sent to the server, along with the reason (e.g., ErrNotConnected,
+// ErrQueueFull or a write error)
+func (c *Client) OnDropped(callback func(msg string, err error)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.droppedCallbacks = append(c.droppedCallbacks, callback)
+}
+
+// newNonce returns a random client-nonce
+func newNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// channelName returns channel in lowercase, the way the server refers to it,
+// with the "#" prefix prepended if it is missing
+func channelName(channel string) string {
+	channel = strings.ToLower(channel)
 	if !strings.HasPrefix(channel, "#") {
 		channel = "#" + channel
 	}
-	c.send("PART %s", channel)
+	return channel
+}
+
+// validChannel returns channel in lowercase with the "#" prefix prepended if it
+// is missing, or ErrInvalidChannel if it is not a valid channel name
+func validChannel(channel string) (string, error) {
+	channel = channelName(channel)
+	if len(channel) < 2 || strings.ContainsAny(channel, " ,\r\n") {
+		return "", ErrInvalidChannel
+	}
+	return channel, nil
 }
 
 func (c *Client) authenticate(nick, pass string) error {
@@ -225,26 +567,80 @@ func (c *Client) authenticate(nick, pass string) error {
 	}
 
 	msg := NewMessage(line)
+	if msg.Command == "NOTICE" {
+		// e.g., "Login authentication failed" or "Improperly formatted auth"
+		return ErrAuthenticationFailed
+	}
 	if msg.Command != "001" {
 		return fmt.Errorf("Unexpected server response: %s", line)
 	}
+	c.setState(StateConnected, StateAuthenticating)
 
 	c.write(fmt.Sprintf("CAP REQ :%s\r\n", strings.Join(caps, " twitch.tv/")))
 
 	return nil
 }
 
-func (c *Client) send(format string, args ...interface{}) {
-	if !c.Connected() {
-		return
+// send queues a message, or runs the dropped callbacks and returns an error if
+// it can't be queued
+func (c *Client) send(format string, args ...interface{}) error {
+	return c.sendAll(fmt.Sprintf(format, args...))
+}
+
+// sendAll queues messages so that they are sent one after another. Either all
+// of the messages are queued or, if there isn't enough room, none of them.
+func (c *Client) sendAll(msgs ...string) error {
+	err := c.enqueue(msgs)
+	if err != nil {
+		for _, msg := range msgs {
+			c.doDroppedCallbacks(msg, err)
+		}
+	}
+	return err
+}
+
+func (c *Client) enqueue(msgs []string) error {
+	// Messages waiting in the send loop take up room in the queue, except for
+	// control messages, which are sent ahead of them
+	control := len(msgs) == 1 && messagePriority(msgs[0]) == priorityControl
+
+	// sendMu is held while checking the state so that Shutdown, which also holds
+	// it, can't begin between the check and queueing the messages
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	c.connectedMu.RLock()
+	state, shuttingDown := c.state, c.shuttingDown
+	c.connectedMu.RUnlock()
+	if state == StateClosing && shuttingDown {
+		// Control messages (e.g., PONG) keep the connection alive while the queue
+		// is flushed, but there is no point in joining channels
+		if !control || joinChannels(msgs[0]) != nil {
+			return ErrShuttingDown
+		}
+	} else if state != StateConnected {
+		return ErrNotConnected
+	}
+	scheduled := int(atomic.LoadInt64(&c.scheduled))
+	if control {
+		scheduled = 0
+	}
+	if cap(c.sendQueue)-len(c.sendQueue)-scheduled < len(msgs) {
+		c.log("Send queue full; discarding message: %s", strings.Join(msgs, "\n"))
+		return ErrQueueFull
 	}
+	for _, msg := range msgs {
+		c.sendQueue <- msg
+	}
+	return nil
+}
 
+// sendWithTags queues a message prefixed with the given IRCv3 tags
+func (c *Client) sendWithTags(tags map[string]string, format string, args ...interface{}) error {
 	msg := fmt.Sprintf(format, args...)
-	select {
-	case c.sendQueue <- msg:
-	default:
-		c.log("Send queue full; discarding message: %s", msg)
+	if len(tags) > 0 {
+		msg = formatTags(tags) + " " + msg
 	}
+	return c.send("%s", msg)
 }
 
 func (c *Client) write(data string) error {
@@ -269,39 +665,177 @@ func (c *Client) log(format string, v ...interface{}) {
 	}
 }
 
+// startSendLoop writes queued messages to the server. Protocol control messages
+// are sent first, then moderation commands, then chat. Control messages have a
+// RateLimiter of their own. Moderation commands and chat share the moderator
+// rate limit, since Twitch counts both towards the same limit, and chat in
+// channels where the client is not a moderator is further limited to maxMessages
+// per perSeconds. Moderation commands take precedence within the shared limit.
+// Chat messages are additionally spaced by the channel's interval, with channels
+// taking turns. Once Shutdown is called, the loop sends QUIT and returns as soon
+// as the queued messages have been sent.
 func (c *Client) startSendLoop(maxMessages, perSeconds float64) {
 	defer c.conn.Close()
-	tokens := maxMessages
-	lastTick := time.Now()
+	_, modLimit := c.rateLimits()
+	modMessages, modPerSeconds := float64(modLimit.Messages), modLimit.Per.Seconds()
+	if modMessages/modPerSeconds < maxMessages/perSeconds {
+		modMessages, modPerSeconds = maxMessages, perSeconds
+	}
+
+	clock := c.clock()
+	userLimiter := newRateLimiter(maxMessages, perSeconds, clock)
+	modLimiter := newRateLimiter(modMessages, modPerSeconds, clock)
+	controlLimiter := newRateLimiter(modMessages, modPerSeconds, clock)
+	joinLimiter := NewRateLimiter(c.joinRateLimit(), clock)
+	c.connectedMu.RLock()
+	shutdown, flushed := c.shutdownChan, c.flushedChan
+	c.connectedMu.RUnlock()
+	flushing := false
+
+	c.sendMu.Lock()
+	joins := c.connectJoins
+	c.connectJoins = nil
+	c.sendMu.Unlock()
+	queues := newPriorityQueues()
+	push := func(data string) {
+		if channels := joinChannels(data); channels != nil {
+			joins = append(joins, channels...)
+			return
+		}
+		p := messagePriority(data)
+		queues.push(p, messageChannel(data), data)
+		if p != priorityControl {
+			atomic.AddInt64(&c.scheduled, 1)
+		}
+	}
 
 	for {
+		// No more messages can be queued once Shutdown is called, and there is no
+		// point in joining channels that are about to be left
 		select {
-		case <-c.doneChan:
+		case <-shutdown:
+			flushing, shutdown, joins = true, nil, nil
+		default:
+		}
+
+		// Move newly queued messages to their channel's queue
+	drain:
+		for {
+			select {
+			case data := <-c.sendQueue:
+				push(data)
+			default:
+				break drain
+			}
+		}
+
+		if flushing && queues.len() == 0 {
+			if err := c.write("QUIT\r\n"); err != nil {
+				c.log("ERROR sending: %s", err)
+			} else {
+				close(flushed)
+			}
+			c.Disconnect()
 			return
-		case data := <-c.sendQueue:
-			if !strings.HasSuffix(data, "\r\n") {
-				data = data + "\r\n"
+		}
+
+		// Join as many waiting channels as the join limit allows
+		var joinWait time.Duration
+		if len(joins) > 0 {
+			if n := joinLimiter.Available(); n > 0 {
+				var data string
+				var batch []string
+				data, batch, joins = nextJoinBatch(joins, n)
+				if err := c.write(data + "\r\n"); err != nil {
+					c.log("ERROR sending: %s", err)
+					c.doDroppedCallbacks(data, err)
+					c.Disconnect()
+					return
+				}
+				for range batch {
+					joinLimiter.Take()
+				}
+				c.joinStarted(batch)
+				continue
 			}
+			joinWait = joinLimiter.Delay()
+		}
 
-			now := time.Now()
-			elapsedTime := now.Sub(lastTick)
-			lastTick = now
-			tokens += elapsedTime.Seconds() * (maxMessages / perSeconds)
+		// Channels blocked by their rate limit are skipped, so that chat in a channel
+		// where the client is a moderator doesn't wait for the user limit of others
+		p, channel, data, ok, wait := queues.pop(clock.Now(), func(p priority, channel string) time.Duration {
+			switch p {
+			case priorityControl:
+				return controlLimiter.Delay()
+			case priorityModeration:
+				return modLimiter.Delay()
+			}
+			wait := modLimiter.Delay()
+			if w := userLimiter.Delay(); w > wait && !c.isSelfModerator(channel) {
+				wait = w
+			}
+			return wait
+		})
+		if !ok {
+			if joinWait > 0 && (wait == 0 || joinWait < wait) {
+				wait = joinWait
+			}
 
-			if tokens >= maxMessages {
-				tokens = maxMessages
-			} else if tokens < 1 {
-				required := 1 - tokens
-				time.Sleep(time.Duration(required * float64(time.Second)))
+			var timeout <-chan time.Time
+			if wait > 0 {
+				timeout = clock.After(wait)
 			}
 
-			if err := c.write(data); err != nil {
-				c.log("ERROR sending: %s", err)
-				c.Disconnect()
+			select {
+			case <-c.doneChan:
 				return
+			case <-shutdown:
+			case data := <-c.sendQueue:
+				push(data)
+			case <-timeout:
+			}
+			continue
+		}
+
+		mod := c.isSelfModerator(channel)
+		if p != priorityControl {
+			atomic.AddInt64(&c.scheduled, -1)
+		}
+
+		if p == priorityChat {
+			data = c.avoidDuplicate(channel, data, clock.Now())
+		}
+		if !strings.HasSuffix(data, "\r\n") {
+			data = data + "\r\n"
+		}
+		if err := c.write(data); err != nil {
+			c.log("ERROR sending: %s", err)
+			c.doDroppedCallbacks(strings.TrimSuffix(data, "\r\n"), err)
+			c.Disconnect()
+			return
+		}
+
+		switch p {
+		case priorityControl:
+			controlLimiter.Take()
+			queues.sent(p, channel, clock.Now(), 0)
+		case priorityModeration:
+			modLimiter.Take()
+			queues.sent(p, channel, clock.Now(), 0)
+		default:
+			modLimiter.Take()
+			if !mod {
+				userLimiter.Take()
 			}
+			c.recordSent(channel, data, clock.Now())
+			queues.sent(p, channel, clock.Now(), c.channelInterval(channel))
+		}
+		c.pendingSays.written(data)
 
-			tokens--
+		select {
+		case <-c.doneChan:
+			return
+		default:
 		}
 	}
 }
@@ -321,7 +855,14 @@ func (c *Client) startRecvLoop() error {
 
 func (c *Client) doCallbacks(line string) {
 	msg := NewMessage(line)
+	if c.parent != nil && msg.Command != "PING" {
+		// A read connection answers PINGs itself and passes everything else on
+		c.parent.doReaderCallbacks(line, &msg)
+		return
+	}
 	if msg.Command == "PRIVMSG" {
+		c.updateBadgeRoles(&msg)
+
 		var m string
 		if len(msg.Params) > 1 {
 			m = msg.Params[1]
@@ -336,21 +877,47 @@ func (c *Client) doCallbacks(line string) {
 				c.doChatCallbacks(&msg)
 			}
 		}
+	} else if msg.Command == "WHISPER" {
+		c.doWhisperCallbacks(&msg)
 	} else if msg.Command == "JOIN" {
 		c.doJoinCallbacks(&msg)
 	} else if msg.Command == "PART" {
 		c.doPartCallbacks(&msg)
 	} else if msg.Command == "USERNOTICE" {
-		msgid := msg.Tags["msg-id"]
-		if msgid == "resub" {
-			c.doResubCallbacks(&msg)
-		} else if msgid == "sub" {
-			c.doSubscriptionCallbacks(&msg)
-		} else if msgid == "subgift" {
-			c.doSubGiftCallbacks(&msg)
-		}
+		c.doUserNoticeCallbacks(&msg)
+	} else if msg.Command == "ROOMSTATE" {
+		c.doRoomStateCallbacks(&msg)
+	} else if msg.Command == "GLOBALUSERSTATE" {
+		c.doGlobalUserStateCallbacks(&msg)
+	} else if msg.Command == "USERSTATE" {
+		c.doUserStateCallbacks(&msg)
+	} else if msg.Command == "MODE" {
+		c.doModeCallbacks(&msg)
+	} else if msg.Command == "353" {
+		c.doNamesReply(&msg)
+	} else if msg.Command == "366" {
+		c.doEndOfNamesCallbacks(&msg)
+	} else if msg.Command == "HOSTTARGET" {
+		c.doHostTargetCallbacks(&msg)
+	} else if msg.Command == "NOTICE" {
+		c.doNoticeCallbacks(&msg)
 	} else if msg.Command == "PING" {
-		c.send(fmt.Sprintf("PONG :%s", msg.Params[0]))
+		c.send("PONG :%s", msg.Params[0])
+	}
+}
+
+func (c *Client) doDroppedCallbacks(msg string, err error) {
+	if c.parent != nil {
+		c.parent.doDroppedCallbacks(msg, err)
+		return
+	}
+
+	c.callbackMu.Lock()
+	callbacks := c.droppedCallbacks
+	c.callbackMu.Unlock()
+
+	for _, cb := range callbacks {
+		cb(msg, err)
 	}
 }
 
@@ -427,7 +994,32 @@ func (c *Client) doChatCallbacks(msg *Message) {
 	}
 }
 
+func (c *Client) doWhisperCallbacks(msg *Message) {
+	c.callbackMu.Lock()
+	callbacks := c.whisperCallbacks
+	eventCallbacks := c.whisperEventCallbacks
+	c.callbackMu.Unlock()
+
+	whisper := newWhisperEvent(msg)
+	for _, cb := range callbacks {
+		cb(whisper.From, msg.Tags, whisper.Message)
+	}
+	for _, cb := range eventCallbacks {
+		cb(whisper)
+	}
+}
+
 func (c *Client) doJoinCallbacks(msg *Message) {
+	c.addUser(msg.Params[0], msg.Prefix.Nick)
+	if c.isOwnNick(msg.Prefix.Nick) {
+		// The server follows the JOIN with a USERSTATE on this connection
+		c.pendingSays.joined(msg.Params[0])
+	}
+	if c.isSelf(msg.Prefix.Nick) {
+		c.selfJoined(msg.Params[0])
+		c.joinFinished(msg.Params[0], nil)
+	}
+
 	c.callbackMu.Lock()
 	callbacks := c.joinCallbacks
 	c.callbackMu.Unlock()
@@ -438,6 +1030,12 @@ func (c *Client) doJoinCallbacks(msg *Message) {
 }
 
 func (c *Client) doPartCallbacks(msg *Message) {
+	c.removeUser(msg.Params[0], msg.Prefix.Nick)
+	if c.isSelf(msg.Prefix.Nick) {
+		c.forgetChannel(msg.Params[0])
+		c.selfParted(msg.Params[0])
+	}
+
 	c.callbackMu.Lock()
 	callbacks := c.partCallbacks
 	c.callbackMu.Unlock()
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"reflect"
 	"strings"
 	"sync"
 	"testing"
@@ -22,6 +23,9 @@ func createClientServer() (*Client, net.Conn) {
 	client.reader = bufio.NewReader(client.conn)
 	client.writer = bufio.NewWriter(client.conn)
 	client.doneChan = make(chan struct{})
+	client.shutdownChan = make(chan struct{})
+	client.flushedChan = make(chan struct{})
+	client.readTimeout = 10 * time.Minute
 	return &client, server
 }
 
@@ -89,6 +93,24 @@ func TestFailedAuthenticate(t *testing.T) {
 	wg.Wait()
 }
 
+func TestRejectedAuthenticate(t *testing.T) {
+	client, server := createClientServer()
+	errs := make(chan error)
+	go func() {
+		errs <- client.authenticate(username, password)
+	}()
+
+	in := bufio.NewReader(server)
+	in.ReadString('\n') // pass
+	in.ReadString('\n') // nick
+	server.Write([]byte(":tmi.twitch.tv NOTICE * :Login authentication failed\r\n"))
+
+	if err := <-errs; err != ErrAuthenticationFailed {
+		t.Errorf("Expected '%s', got '%v'", ErrAuthenticationFailed, err)
+	}
+	server.Close()
+}
+
 func TestSend(t *testing.T) {
 	test := "test\n"
 	client := NewClient(Options{})
@@ -102,7 +124,7 @@ func TestSend(t *testing.T) {
 	default:
 	}
 
-	client.connected = true
+	client.state = StateConnected
 	client.send(test)
 	client.send("%s", test)
 
@@ -164,7 +186,7 @@ func TestJoin(t *testing.T) {
 	channel2 := "#test2"
 	client := NewClient(Options{})
 	client.sendQueue = make(chan string, sendBufferSize)
-	client.connected = true
+	client.state = StateConnected
 	client.Join(channel1)
 	client.Join(channel2)
 
@@ -194,7 +216,7 @@ func TestPart(t *testing.T) {
 	channel2 := "#test2"
 	client := NewClient(Options{})
 	client.sendQueue = make(chan string, sendBufferSize)
-	client.connected = true
+	client.state = StateConnected
 	client.Part(channel1)
 	client.Part(channel2)
 
@@ -224,16 +246,18 @@ func TestSendLoop(t *testing.T) {
 	perSeconds := 2
 	var wg sync.WaitGroup
 	client, server := createClientServer()
+	clock := newFakeClock()
+	client.options.Clock = clock
 	client.sendQueue = make(chan string, sendBufferSize)
-	client.connected = true
+	client.state = StateConnected
+	wg.Add(1)
 	go func() {
-		wg.Add(1)
 		defer wg.Done()
 
 		client.startSendLoop(float64(maxBurst), float64(perSeconds))
 	}()
 
-	in := bufio.NewReader(server)
+	lines := readLines(server)
 
 	for i := 0; i < maxBurst*2; i++ {
 		client.send("%d", i)
@@ -242,8 +266,11 @@ func TestSendLoop(t *testing.T) {
 	data := make([]string, maxBurst*2)
 	recv := make([]time.Time, maxBurst*2)
 	for i := 0; i < maxBurst*2; i++ {
-		data[i], _ = in.ReadString('\n')
-		recv[i] = time.Now()
+		if i >= maxBurst {
+			clock.step()
+		}
+		data[i] = <-lines
+		recv[i] = clock.Now()
 	}
 
 	delta := recv[len(recv)-1].Sub(recv[0])
@@ -251,6 +278,9 @@ func TestSendLoop(t *testing.T) {
 	if delta < minTime {
 		t.Errorf("Expected delta > %s, got %s (%s - %s)", minTime, delta, recv[len(recv)-1], recv[0])
 	}
+	if delta := recv[maxBurst-1].Sub(recv[0]); delta != 0 {
+		t.Errorf("Expected burst delta 0s, got %s", delta)
+	}
 
 	for i := 0; i < maxBurst*2; i++ {
 		expected := fmt.Sprintf("%d\r\n", i)
@@ -263,7 +293,7 @@ func TestSendLoop(t *testing.T) {
 	server.Close()
 	client.send("X")
 
-	wg.Wait()
+	clock.wait(&wg)
 }
 
 func TestEndRecvLoop(t *testing.T) {
@@ -590,7 +620,7 @@ func TestOnGiftSub(t *testing.T) {
 func TestSay(t *testing.T) {
 	client := NewClient(Options{})
 	client.sendQueue = make(chan string, sendBufferSize)
-	client.connected = true
+	client.state = StateConnected
 
 	channel := "channel1"
 	msg := "This is a test"
@@ -618,10 +648,148 @@ func TestSay(t *testing.T) {
 	}
 }
 
+func TestSaySplit(t *testing.T) {
+	client := NewClient(Options{SplitLongMessages: true, NumberSplitMessages: true})
+	client.sendQueue = make(chan string, 3)
+	client.state = StateConnected
+
+	msg := strings.Repeat("Kappa ", 100) + "end"
+	if err := client.Say("channel1", msg); err != nil {
+		t.Errorf("Expected 'nil', got '%s'", err)
+	}
+	if len(client.sendQueue) != 2 {
+		t.Fatalf("Expected '2', got '%d'", len(client.sendQueue))
+	}
+
+	first := <-client.sendQueue
+	second := <-client.sendQueue
+	if !strings.HasPrefix(first, "PRIVMSG #channel1 :(1/2) Kappa") {
+		t.Errorf("Expected first part, got '%s'", first)
+	}
+	if !strings.HasPrefix(second, "PRIVMSG #channel1 :(2/2) Kappa") || !strings.HasSuffix(second, " end") {
+		t.Errorf("Expected second part, got '%s'", second)
+	}
+
+	// All parts must fit in the queue
+	client.Say("channel1", "x")
+	client.Say("channel1", "x")
+	if err := client.Whisper("testnick", msg); err != ErrQueueFull {
+		t.Errorf("Expected '%s', got '%v'", ErrQueueFull, err)
+	}
+	<-client.sendQueue
+	if err := client.Whisper("testnick", msg); err != nil {
+		t.Errorf("Expected 'nil', got '%s'", err)
+	}
+	<-client.sendQueue
+	if data := <-client.sendQueue; !strings.HasPrefix(data, "PRIVMSG #jtv :/w testnick (1/2) Kappa") {
+		t.Errorf("Expected whisper part, got '%s'", data)
+	}
+}
+
+func TestSayWithTags(t *testing.T) {
+	client := NewClient(Options{})
+	client.sendQueue = make(chan string, sendBufferSize)
+	client.state = StateConnected
+
+	nonce, err := client.SayWithTags("channel1", "This is a test", map[string]string{"custom": "a b;c"})
+	if err != nil {
+		t.Errorf("Expected 'nil', got '%s'", err)
+	}
+	if len(nonce) != 32 {
+		t.Errorf("Expected 32 character nonce, got '%s'", nonce)
+	}
+
+	select {
+	case data := <-client.sendQueue:
+		expect := fmt.Sprintf(`@client-nonce=%s;custom=a\sb\:c PRIVMSG #channel1 :This is a test`, nonce)
+		if data != expect {
+			t.Errorf("Expected '%s', got '%s'", expect, data)
+		}
+	default:
+		t.Error("Expected nonempty channel")
+	}
+
+	// Malformed keys are rejected
+	if _, err := client.SayWithTags("channel1", "hi", map[string]string{"a\r\nPRIVMSG #other :spam\r\n": "1"}); err != ErrInvalidTag {
+		t.Errorf("Expected '%s', got '%v'", ErrInvalidTag, err)
+	}
+	if n := len(client.sendQueue); n != 0 {
+		t.Errorf("Expected '0', got '%d'", n)
+	}
+
+	// Nonce supplied by the caller
+	nonce, _ = client.SayWithTags("#channel1", "This is a test", map[string]string{"client-nonce": "abc"})
+	if nonce != "abc" {
+		t.Errorf("Expected 'abc', got '%s'", nonce)
+	}
+
+	select {
+	case data := <-client.sendQueue:
+		expect := "@client-nonce=abc PRIVMSG #channel1 :This is a test"
+		if data != expect {
+			t.Errorf("Expected '%s', got '%s'", expect, data)
+		}
+	default:
+		t.Error("Expected nonempty channel")
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	client := NewClient(Options{})
+	client.sendQueue = make(chan string, 1)
+	var dropped []string
+	var droppedErrs []error
+	client.OnDropped(func(msg string, err error) {
+		dropped = append(dropped, msg)
+		droppedErrs = append(droppedErrs, err)
+	})
+
+	// Client not yet connected
+	if err := client.Say("channel1", "test"); err != ErrNotConnected {
+		t.Errorf("Expected '%s', got '%v'", ErrNotConnected, err)
+	}
+
+	client.state = StateConnected
+	if err := client.Say("", "test"); err != ErrInvalidChannel {
+		t.Errorf("Expected '%s', got '%v'", ErrInvalidChannel, err)
+	}
+	if err := client.Join("#"); err != ErrInvalidChannel {
+		t.Errorf("Expected '%s', got '%v'", ErrInvalidChannel, err)
+	}
+	if err := client.Part("a b"); err != ErrInvalidChannel {
+		t.Errorf("Expected '%s', got '%v'", ErrInvalidChannel, err)
+	}
+	if err := client.Whisper("", "test"); err != ErrInvalidUser {
+		t.Errorf("Expected '%s', got '%v'", ErrInvalidUser, err)
+	}
+
+	if err := client.Say("channel1", "100%"); err != nil {
+		t.Errorf("Expected 'nil', got '%s'", err)
+	}
+	if err := client.Join("channel1"); err != ErrQueueFull {
+		t.Errorf("Expected '%s', got '%v'", ErrQueueFull, err)
+	}
+
+	if data := <-client.sendQueue; data != "PRIVMSG #channel1 :100%" {
+		t.Errorf("Expected 'PRIVMSG #channel1 :100%%', got '%s'", data)
+	}
+
+	expected := []string{"PRIVMSG #channel1 :test", "JOIN #channel1"}
+	expectedErrs := []error{ErrNotConnected, ErrQueueFull}
+	if len(dropped) != len(expected) {
+		t.Fatalf("Expected '%v', got '%v'", expected, dropped)
+	}
+	for i := range expected {
+		if dropped[i] != expected[i] || droppedErrs[i] != expectedErrs[i] {
+			t.Errorf("Expected '%s' (%s), got '%s' (%s)", expected[i], expectedErrs[i], dropped[i], droppedErrs[i])
+		}
+	}
+}
+
 func TestWhisper(t *testing.T) {
 	client := NewClient(Options{})
 	client.sendQueue = make(chan string, sendBufferSize)
-	client.connected = true
+	client.state = StateConnected
 
 	nick := "testnick"
 	msg := "This is a test"
@@ -638,6 +806,64 @@ func TestWhisper(t *testing.T) {
 	}
 }
 
+func TestOnWhisper(t *testing.T) {
+	client := NewClient(Options{})
+	expectedNick := "test_nick"
+	expectedTags := map[string]string{"display-name": "Test_Nick", "user-id": "1337"}
+	expectedMsg := "Test message!"
+	var gotNick string
+	var gotTags map[string]string
+	var gotMsg string
+	client.OnWhisper(func(from string, tags map[string]string, msg string) {
+		gotNick = from
+		gotTags = tags
+		gotMsg = msg
+	})
+
+	line := fmt.Sprintf("@display-name=Test_Nick;user-id=1337 :%s!%s@%s.tmi.twitch.tv WHISPER %s :%s\r\n",
+		expectedNick, expectedNick, expectedNick, username, expectedMsg)
+	client.doCallbacks(line)
+
+	if expectedNick != gotNick {
+		t.Errorf("Expected '%s', got '%s'", expectedNick, gotNick)
+	}
+	if expectedMsg != gotMsg {
+		t.Errorf("Expected '%s', got '%s'", expectedMsg, gotMsg)
+	}
+	for k := range expectedTags {
+		if expectedTags[k] != gotTags[k] {
+			t.Errorf("Expected '%s', got '%s'", expectedTags[k], gotTags[k])
+		}
+	}
+}
+
+func TestOnWhisperEvent(t *testing.T) {
+	client := NewClient(Options{})
+	var got WhisperEvent
+	client.OnWhisperEvent(func(whisper WhisperEvent) {
+		got = whisper
+	})
+
+	client.doCallbacks("@badges=staff/1;color=#1E90FF;display-name=Test_Nick;message-id=7;thread-id=1337_42;user-id=1337 " +
+		":test_nick!test_nick@test_nick.tmi.twitch.tv WHISPER " + username + " :Test message!\r\n")
+
+	expected := WhisperEvent{
+		From:        "test_nick",
+		DisplayName: "Test_Nick",
+		UserID:      "1337",
+		To:          username,
+		MessageID:   "7",
+		ThreadID:    "1337_42",
+		Color:       "#1E90FF",
+		Badges:      map[string]string{"staff": "1"},
+		Message:     "Test message!",
+	}
+	got.Tags = nil
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected '%+v', got '%+v'", expected, got)
+	}
+}
+
 func TestCloseConnection(t *testing.T) {
 	var wg sync.WaitGroup
 	client, server := createClientServer()
@@ -760,7 +986,7 @@ func TestDisconnect(t *testing.T) {
 func TestOnPing(t *testing.T) {
 	client := NewClient(Options{})
 	client.sendQueue = make(chan string, 1)
-	client.connected = true
+	client.state = StateConnected
 	client.doCallbacks("PING :tmi.twitch.tv\r\n")
 
 	line := <-client.sendQueue
@@ -0,0 +1,188 @@
+package gotirc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrAckTimeout is returned by SayAndWait when the server neither acknowledged
+// nor rejected the message in time
+var ErrAckTimeout = errors.New("no response from server")
+
+// NoticeError is returned when the server rejects a message or JOIN with a
+// NOTICE
+type NoticeError struct {
+	Channel string
+	MsgID   NoticeID
+	Text    string
+}
+
+func (e *NoticeError) Error() string {
+	return fmt.Sprintf("%s: rejected by server (%s): %s", e.Channel, e.MsgID, e.Text)
+}
+
+type pendingSay struct {
+	channel string
+	nonce   string
+	written chan struct{}
+	result  chan error
+}
+
+type pendingSays struct {
+	mu   sync.Mutex
+	list []*pendingSay
+
+	// The number of USERSTATEs expected per channel in response to the client
+	// joining it rather than to a message
+	joinUserStates map[string]int
+}
+
+func (p *pendingSays) add(s *pendingSay) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.list = append(p.list, s)
+}
+
+func (p *pendingSays) remove(s *pendingSay) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := range p.list {
+		if p.list[i] == s {
+			p.list = append(p.list[:i], p.list[i+1:]...)
+			return
+		}
+	}
+}
+
+// written records that the send loop wrote data to the server, which starts the
+// acknowledgement timeout of the pending message with the same client-nonce
+func (p *pendingSays) written(data string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.list) == 0 || !strings.HasPrefix(data, "@") {
+		return
+	}
+	nonce := NewMessage(data).Tags["client-nonce"]
+	for _, s := range p.list {
+		if nonce != "" && s.nonce == nonce {
+			select {
+			case s.written <- struct{}{}:
+			default:
+			}
+			return
+		}
+	}
+}
+
+// joined records that the server will send a USERSTATE in response to the client
+// joining a channel
+func (p *pendingSays) joined(channel string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.joinUserStates == nil {
+		p.joinUserStates = make(map[string]int)
+	}
+	p.joinUserStates[channel]++
+}
+
+// forgetJoins forgets the USERSTATEs expected in response to JOINs, e.g., when
+// the connection closes
+func (p *pendingSays) forgetJoins() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.joinUserStates = nil
+}
+
+// acknowledge completes the pending message acknowledged by a USERSTATE. A
+// USERSTATE without a client-nonce that answers a JOIN acknowledges nothing.
+func (p *pendingSays) acknowledge(channel, nonce string) {
+	p.mu.Lock()
+	if nonce == "" && p.joinUserStates[channel] > 0 {
+		p.joinUserStates[channel]--
+		if p.joinUserStates[channel] == 0 {
+			delete(p.joinUserStates, channel)
+		}
+		p.mu.Unlock()
+		return
+	}
+	p.mu.Unlock()
+	p.resolve(channel, nonce, nil)
+}
+
+// resolve completes the pending message with the given client-nonce or, if the
+// server did not echo a nonce, the oldest pending message in the channel. The
+// latter is a heuristic: the server answers messages in order, so a response
+// without a nonce is assumed to belong to the oldest message still waiting.
+func (p *pendingSays) resolve(channel, nonce string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, s := range p.list {
+		if (nonce != "" && s.nonce == nonce) || (nonce == "" && s.channel == channel) {
+			p.list = append(p.list[:i], p.list[i+1:]...)
+			s.result <- err
+			return
+		}
+	}
+}
+
+// SayAndWait sends a message to a channel and waits for the server to accept or
+// reject it. It returns nil when the server acknowledges the message with a
+// USERSTATE, a *NoticeError when the server rejects it with a NOTICE, the error
+// returned by Say if the message could not be queued, ErrAckTimeout if the
+// server does not respond in time, ErrNotConnected if the connection closes
+// before the message is written, or the context's error if ctx is done first.
+// The time allowed for the server to respond (Options.AckTimeout) starts once the
+// message is written, so time spent waiting for the rate limits doesn't count.
+//
+// The server echoes the message's client-nonce in its response. If it doesn't,
+// the response is matched to the oldest message waiting in the channel, ignoring
+// the USERSTATE the server sends after the client joins the channel.
+func (c *Client) SayAndWait(ctx context.Context, channel, msg string) error {
+	channel, err := validChannel(channel)
+	if err != nil {
+		return err
+	}
+
+	s := &pendingSay{
+		channel: channel,
+		nonce:   newNonce(),
+		written: make(chan struct{}, 1),
+		result:  make(chan error, 1),
+	}
+	c.pendingSays.add(s)
+	defer c.pendingSays.remove(s)
+
+	c.connectedMu.RLock()
+	done := c.doneChan
+	c.connectedMu.RUnlock()
+
+	if err := c.sendWithTags(map[string]string{"client-nonce": s.nonce}, "PRIVMSG %s :%s", channel, msg); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-s.result:
+		return err
+	case <-done:
+		return ErrNotConnected
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.written:
+	}
+
+	timer := time.NewTimer(c.ackTimeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-s.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return ErrAckTimeout
+	}
+}
@@ -0,0 +1,155 @@
+package gotirc
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// ackNext reads the next queued message and passes the server's response,
+// built from the message's client-nonce, to the client
+func ackNext(t *testing.T, client *Client, respond func(nonce string) string) {
+	data := <-client.sendQueue
+	msg := NewMessage(data)
+	if msg.Command != "PRIVMSG" {
+		t.Errorf("Expected 'PRIVMSG', got '%s'", msg.Command)
+	}
+	client.doCallbacks(respond(msg.Tags["client-nonce"]))
+}
+
+func TestSayAndWait(t *testing.T) {
+	client := NewClient(Options{})
+	client.sendQueue = make(chan string, sendBufferSize)
+	client.state = StateConnected
+
+	// Acknowledged
+	go ackNext(t, client, func(nonce string) string {
+		return createMessage("USERSTATE", "#test", nil, map[string]string{"client-nonce": nonce, "mod": "0"})
+	})
+	if err := client.SayAndWait(context.Background(), "test", "hello"); err != nil {
+		t.Errorf("Expected 'nil', got '%s'", err)
+	}
+
+	// Rejected
+	go ackNext(t, client, func(nonce string) string {
+		return createMessage("NOTICE", "#test", []string{"Your message is identical to the one you sent less than 30 seconds ago."},
+			map[string]string{"msg-id": "msg_duplicate"})
+	})
+	err := client.SayAndWait(context.Background(), "test", "hello")
+	if noticeErr, ok := err.(*NoticeError); !ok || noticeErr.MsgID != NoticeDuplicate {
+		t.Errorf("Expected '*NoticeError', got '%v'", err)
+	}
+
+	// Command refused
+	go ackNext(t, client, func(nonce string) string {
+		return createMessage("NOTICE", "#test", []string{"You don't have permission to perform that action."},
+			map[string]string{"msg-id": "no_permission"})
+	})
+	err = client.SayAndWait(context.Background(), "test", "/ban user")
+	if noticeErr, ok := err.(*NoticeError); !ok || noticeErr.MsgID != NoticeNoPermission {
+		t.Errorf("Expected '*NoticeError', got '%v'", err)
+	}
+
+	// Not acknowledged. The timeout starts once the message is written, so time
+	// spent in the send queue doesn't count.
+	client.ackTimeout = 50 * time.Millisecond
+	done := make(chan error)
+	go func() {
+		done <- client.SayAndWait(context.Background(), "test", "hello")
+	}()
+	data := <-client.sendQueue
+	select {
+	case err := <-done:
+		t.Errorf("Expected no timeout before the message is written, got '%v'", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	client.pendingSays.written(data)
+	if err := <-done; err != ErrAckTimeout {
+		t.Errorf("Expected '%s', got '%v'", ErrAckTimeout, err)
+	}
+
+	// Context cancelled
+	client.ackTimeout = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := client.SayAndWait(ctx, "test", "hello"); err != context.DeadlineExceeded {
+		t.Errorf("Expected '%s', got '%v'", context.DeadlineExceeded, err)
+	}
+	<-client.sendQueue
+
+	// Not connected
+	client.state = StateDisconnected
+	if err := client.SayAndWait(context.Background(), "test", "hello"); err != ErrNotConnected {
+		t.Errorf("Expected '%s', got '%v'", ErrNotConnected, err)
+	}
+}
+
+func TestAckTimeoutOption(t *testing.T) {
+	if timeout := NewClient(Options{}).ackTimeout; timeout != 10*time.Second {
+		t.Errorf("Expected '10s', got '%s'", timeout)
+	}
+	if timeout := NewClient(Options{AckTimeout: time.Second}).ackTimeout; timeout != time.Second {
+		t.Errorf("Expected '1s', got '%s'", timeout)
+	}
+}
+
+func TestSayAndWaitIgnoresJoinUserState(t *testing.T) {
+	client := NewClient(Options{})
+	client.nick = "test_nick"
+	client.sendQueue = make(chan string, sendBufferSize)
+	client.state = StateConnected
+
+	done := make(chan error)
+	go func() {
+		done <- client.SayAndWait(context.Background(), "test", "hello")
+	}()
+	<-client.sendQueue
+
+	// The USERSTATE that follows a JOIN doesn't acknowledge the message
+	client.doCallbacks(":test_nick!test_nick@test_nick.tmi.twitch.tv JOIN #test\r\n")
+	client.doCallbacks(createMessage("USERSTATE", "#test", nil, map[string]string{"mod": "0"}))
+	select {
+	case err := <-done:
+		t.Errorf("Expected no acknowledgement, got '%v'", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	client.doCallbacks(createMessage("USERSTATE", "#test", nil, map[string]string{"mod": "0"}))
+	if err := <-done; err != nil {
+		t.Errorf("Expected 'nil', got '%s'", err)
+	}
+}
+
+func TestSayAndWaitTimeoutStartsWhenWritten(t *testing.T) {
+	client, server := createClientServer()
+	client.options = Options{Clock: newFakeClock()}
+	client.sendQueue = make(chan string, sendBufferSize)
+	client.state = StateConnected
+	client.ackTimeout = 50 * time.Millisecond
+	lines := readLines(server)
+
+	loopDone := make(chan struct{})
+	go func() {
+		defer close(loopDone)
+		client.startSendLoop(100, 1)
+	}()
+	defer func() {
+		client.Disconnect()
+		<-loopDone
+		server.Close()
+	}()
+
+	done := make(chan error)
+	go func() {
+		done <- client.SayAndWait(context.Background(), "test", "hello")
+	}()
+	<-lines
+	select {
+	case err := <-done:
+		if err != ErrAckTimeout {
+			t.Errorf("Expected '%s', got '%v'", ErrAckTimeout, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the timeout to start once the message was written")
+	}
+}
@@ -0,0 +1,65 @@
+package gotirc
+
+import (
+	"time"
+	"unicode/utf8"
+)
+
+// duplicateWindow is how long Twitch remembers the last message sent to a
+// channel when rejecting identical messages (msg_duplicate)
+const duplicateWindow = 30 * time.Second
+
+// duplicateSpacer is appended to a message that would otherwise be identical to
+// the previous message. Chat clients don't render the U+E0000 tag character, so
+// the message looks unchanged.
+const duplicateSpacer = " \U000E0000"
+
+type sentMessage struct {
+	msg  string
+	time time.Time
+}
+
+// avoidDuplicate returns data, a PRIVMSG about to be sent to channel at now,
+// altered invisibly if AvoidDuplicates is set and its text is identical to the
+// last message sent to the channel within the duplicate window. Whispers are
+// left alone.
+func (c *Client) avoidDuplicate(channel, data string, now time.Time) string {
+	if !c.options.AvoidDuplicates || channel == "#jtv" {
+		return data
+	}
+	msg := NewMessage(data)
+	if msg.Command != "PRIVMSG" || len(msg.Params) < 2 {
+		return data
+	}
+	text := msg.Params[1]
+	if utf8.RuneCountInString(text+duplicateSpacer) > maxMessageLength {
+		return data
+	}
+
+	c.lastSentMu.Lock()
+	defer c.lastSentMu.Unlock()
+	last, ok := c.lastSent[channel]
+	if ok && last.msg == text && now.Sub(last.time) < duplicateWindow {
+		return data + duplicateSpacer
+	}
+	return data
+}
+
+// recordSent records the text of data, a PRIVMSG sent to channel at now, as the
+// last message sent to the channel
+func (c *Client) recordSent(channel, data string, now time.Time) {
+	if !c.options.AvoidDuplicates || channel == "#jtv" {
+		return
+	}
+	msg := NewMessage(data)
+	if msg.Command != "PRIVMSG" || len(msg.Params) < 2 {
+		return
+	}
+
+	c.lastSentMu.Lock()
+	defer c.lastSentMu.Unlock()
+	if c.lastSent == nil {
+		c.lastSent = make(map[string]sentMessage)
+	}
+	c.lastSent[channel] = sentMessage{msg: msg.Params[1], time: now}
+}
@@ -0,0 +1,119 @@
+package gotirc
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// startDuplicateClient starts the send loop of a client that avoids duplicates
+// and returns the client, its fake clock and the lines it writes
+func startDuplicateClient(t *testing.T, o Options) (*Client, *fakeClock, <-chan string) {
+	client, server := createClientServer()
+	clock := newFakeClock()
+	o.AvoidDuplicates = true
+	o.Clock = clock
+	client.options = o
+	client.sendQueue = make(chan string, sendBufferSize)
+	client.state = StateConnected
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		client.startSendLoop(100, 1)
+	}()
+	t.Cleanup(func() {
+		client.Disconnect()
+		wg.Wait()
+		server.Close()
+	})
+	return client, clock, readLines(server)
+}
+
+// nextLine advances the clock whenever the send loop waits until it writes a
+// line. Timers the send loop abandoned may fire first, so it may take several
+// steps.
+func nextLine(clock *fakeClock, lines <-chan string) string {
+	for {
+		select {
+		case line := <-lines:
+			return line
+		case <-clock.pending:
+			clock.advanceToNext()
+		}
+	}
+}
+
+func TestAvoidDuplicate(t *testing.T) {
+	client, clock, lines := startDuplicateClient(t, Options{})
+
+	// Messages are compared with the previous message when they are sent, so a
+	// duplicate waiting in the queue behind the original is altered
+	client.Say("channel1", "status")
+	client.Say("channel1", "status")
+	client.Say("channel1", "status")
+	client.Say("channel2", "status")
+	client.Whisper("user", "status")
+	client.Whisper("user", "status")
+
+	// Channels take turns, so compare the lines sent to each channel
+	expected := map[string][]string{
+		"#channel1": {"status", "status" + duplicateSpacer, "status"},
+		"#channel2": {"status"},
+		"#jtv":      {"/w user status", "/w user status"},
+	}
+	got := make(map[string][]string)
+	for i := 0; i < 6; i++ {
+		line := nextLine(clock, lines)
+		msg := NewMessage(line)
+		got[msg.Params[0]] = append(got[msg.Params[0]], msg.Params[1])
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected '%q', got '%q'", expected, got)
+	}
+}
+
+func TestAvoidDuplicateWindow(t *testing.T) {
+	client := NewClient(Options{})
+	sent := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	data := "PRIVMSG #channel1 :status"
+
+	// Disabled by default
+	client.recordSent("#channel1", data, sent)
+	if got := client.avoidDuplicate("#channel1", data, sent); got != data {
+		t.Errorf("Expected '%q', got '%q'", data, got)
+	}
+
+	// The window is measured from when the previous message was sent
+	client.options.AvoidDuplicates = true
+	client.recordSent("#channel1", data, sent)
+	if got := client.avoidDuplicate("#channel1", data, sent.Add(duplicateWindow-time.Second)); got != data+duplicateSpacer {
+		t.Errorf("Expected '%q', got '%q'", data+duplicateSpacer, got)
+	}
+	if got := client.avoidDuplicate("#channel1", data, sent.Add(duplicateWindow)); got != data {
+		t.Errorf("Expected '%q', got '%q'", data, got)
+	}
+}
+
+func TestAvoidDuplicateSplit(t *testing.T) {
+	client, clock, lines := startDuplicateClient(t, Options{SplitLongMessages: true})
+
+	// Both full parts are identical, so the second one is altered
+	client.Say("channel1", strings.Repeat("a", 2*maxMessageLength))
+	altered := false
+	for i := 0; i < 3; i++ {
+		line := nextLine(clock, lines)
+		text := NewMessage(line).Params[1]
+		if n := utf8.RuneCountInString(text); n > maxMessageLength {
+			t.Errorf("Expected at most %d characters, got %d", maxMessageLength, n)
+		}
+		altered = altered || strings.HasSuffix(text, duplicateSpacer)
+	}
+	if !altered {
+		t.Error("Expected an altered part")
+	}
+}
@@ -0,0 +1,129 @@
+package gotirc
+
+import "strconv"
+
+// GiftBombEvent groups a mystery gift (submysterygift) with the individual gifts
+// (subgift or anonsubgift) that were given as part of it
+type GiftBombEvent struct {
+	OriginID string              // The msg-param-origin-id shared by the notices
+	Gifter   string              // Display name of the gifter; empty if anonymous
+	Count    int                 // Number of gifts announced by the mystery gift
+	Tags     map[string]string   // Tags of the submysterygift notice
+	Gifts    []map[string]string // Tags of each individual gift received
+}
+
+type pendingGiftBomb struct {
+	channel string
+	event   GiftBombEvent
+	stop    func()
+}
+
+// OnGiftBomb adds an event callback for when all of the individual gifts of a
+// mystery gift have been received. If some gifts don't arrive within a few
+// seconds, the callback is run with the gifts received so far. In that case it
+// runs on a timer goroutine, concurrently with the other callbacks. Gift bombs
+// that are still incomplete when the connection closes are discarded.
+//
+// Gifts are only grouped while at least one OnGiftBomb callback is registered;
+// OnSubGift and OnAnonSubGift callbacks still run for each individual gift.
+func (c *Client) OnGiftBomb(callback func(channel string, bomb GiftBombEvent)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.giftBombCallbacks = append(c.giftBombCallbacks, callback)
+}
+
+func (c *Client) startGiftBomb(msg *Message) {
+	c.callbackMu.Lock()
+	enabled := len(c.giftBombCallbacks) > 0
+	c.callbackMu.Unlock()
+
+	originID := msg.Tags["msg-param-origin-id"]
+	if !enabled || originID == "" {
+		return
+	}
+
+	count, _ := strconv.Atoi(msg.Tags["msg-param-mass-gift-count"])
+	bomb := &pendingGiftBomb{
+		channel: msg.Params[0],
+		event: GiftBombEvent{
+			OriginID: originID,
+			Count:    count,
+			Tags:     msg.Tags,
+		},
+	}
+	if msg.Tags["login"] != "ananonymousgifter" {
+		bomb.event.Gifter = msg.Tags["display-name"]
+	}
+
+	c.giftBombMu.Lock()
+	if c.giftBombs == nil {
+		c.giftBombs = make(map[string]*pendingGiftBomb)
+	}
+	if old, ok := c.giftBombs[originID]; ok {
+		old.stop()
+	}
+	c.giftBombs[originID] = bomb
+	bomb.stop = c.afterFunc(c.giftBombTimeout, func() {
+		c.finishGiftBomb(bomb)
+	})
+	c.giftBombMu.Unlock()
+
+	if count <= 0 {
+		c.finishGiftBomb(bomb)
+	}
+}
+
+func (c *Client) addGiftBombGift(msg *Message) {
+	originID := msg.Tags["msg-param-origin-id"]
+	if originID == "" {
+		return
+	}
+
+	c.giftBombMu.Lock()
+	bomb, ok := c.giftBombs[originID]
+	complete := false
+	if ok {
+		bomb.event.Gifts = append(bomb.event.Gifts, msg.Tags)
+		complete = len(bomb.event.Gifts) >= bomb.event.Count
+	}
+	c.giftBombMu.Unlock()
+
+	if complete {
+		c.finishGiftBomb(bomb)
+	}
+}
+
+// finishGiftBomb runs the gift bomb callbacks for bomb, unless it has already
+// been finished or discarded
+func (c *Client) finishGiftBomb(bomb *pendingGiftBomb) {
+	originID := bomb.event.OriginID
+	c.giftBombMu.Lock()
+	ok := c.giftBombs[originID] == bomb
+	if ok {
+		delete(c.giftBombs, originID)
+		bomb.stop()
+	}
+	c.giftBombMu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	c.callbackMu.Lock()
+	callbacks := c.giftBombCallbacks
+	c.callbackMu.Unlock()
+
+	for _, cb := range callbacks {
+		cb(bomb.channel, bomb.event)
+	}
+}
+
+// discardGiftBombs forgets the incomplete gift bombs and stops their timers
+func (c *Client) discardGiftBombs() {
+	c.giftBombMu.Lock()
+	defer c.giftBombMu.Unlock()
+	for _, bomb := range c.giftBombs {
+		bomb.stop()
+	}
+	c.giftBombs = make(map[string]*pendingGiftBomb)
+}
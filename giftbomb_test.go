@@ -0,0 +1,137 @@
+package gotirc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOnGiftBomb(t *testing.T) {
+	client := NewClient(Options{})
+	expectedChan := "#test"
+	var gotChan string
+	var gotBomb GiftBombEvent
+	bombs := 0
+	gifts := 0
+	client.OnSubGift(func(channel string, tags map[string]string, msg string) {
+		gifts++
+	})
+	client.OnGiftBomb(func(channel string, bomb GiftBombEvent) {
+		bombs++
+		gotChan, gotBomb = channel, bomb
+	})
+
+	client.doCallbacks(createMessage("USERNOTICE", expectedChan, nil, map[string]string{
+		"msg-id":                    "submysterygift",
+		"display-name":              "GiftGiver1337",
+		"login":                     "giftgiver1337",
+		"msg-param-mass-gift-count": "2",
+		"msg-param-origin-id":       "abc",
+	}))
+	client.doCallbacks(createMessage("USERNOTICE", expectedChan, nil, map[string]string{
+		"msg-id":                        "subgift",
+		"msg-param-origin-id":           "abc",
+		"msg-param-recipient-user-name": "recipient1",
+	}))
+
+	// Gift that is not part of the mystery gift
+	client.doCallbacks(createMessage("USERNOTICE", expectedChan, nil, map[string]string{
+		"msg-id":              "subgift",
+		"msg-param-origin-id": "xyz",
+	}))
+
+	if bombs != 0 {
+		t.Errorf("Expected '0' calls, got '%d'", bombs)
+	}
+
+	client.doCallbacks(createMessage("USERNOTICE", expectedChan, nil, map[string]string{
+		"msg-id":                        "subgift",
+		"msg-param-origin-id":           "abc",
+		"msg-param-recipient-user-name": "recipient2",
+	}))
+
+	if bombs != 1 {
+		t.Fatalf("Expected '1' call, got '%d'", bombs)
+	}
+	if gifts != 3 {
+		t.Errorf("Expected '3' calls, got '%d'", gifts)
+	}
+	if expectedChan != gotChan {
+		t.Errorf("Expected '%s', got '%s'", expectedChan, gotChan)
+	}
+	if gotBomb.OriginID != "abc" || gotBomb.Gifter != "GiftGiver1337" || gotBomb.Count != 2 {
+		t.Errorf("Unexpected event '%+v'", gotBomb)
+	}
+	if len(gotBomb.Gifts) != 2 || gotBomb.Gifts[1]["msg-param-recipient-user-name"] != "recipient2" {
+		t.Errorf("Unexpected gifts '%v'", gotBomb.Gifts)
+	}
+}
+
+func TestGiftBombTimeout(t *testing.T) {
+	client := NewClient(Options{})
+	client.giftBombTimeout = 50 * time.Millisecond
+	done := make(chan GiftBombEvent, 1)
+	client.OnGiftBomb(func(channel string, bomb GiftBombEvent) {
+		done <- bomb
+	})
+
+	client.doCallbacks(createMessage("USERNOTICE", "#test", nil, map[string]string{
+		"msg-id":                    "submysterygift",
+		"login":                     "ananonymousgifter",
+		"msg-param-mass-gift-count": "3",
+		"msg-param-origin-id":       "abc",
+	}))
+	client.doCallbacks(createMessage("USERNOTICE", "#test", nil, map[string]string{
+		"msg-id":              "anonsubgift",
+		"msg-param-origin-id": "abc",
+	}))
+
+	select {
+	case bomb := <-done:
+		if bomb.Gifter != "" {
+			t.Errorf("Expected '', got '%s'", bomb.Gifter)
+		}
+		if len(bomb.Gifts) != 1 {
+			t.Errorf("Expected '1' gift, got '%d'", len(bomb.Gifts))
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected gift bomb after timeout")
+	}
+}
+
+func TestGiftBombDiscarded(t *testing.T) {
+	client, server := createClientServer()
+	defer server.Close()
+	clock := newFakeClock()
+	client.options.Clock = clock
+	client.state = StateConnected
+	client.giftBombTimeout = 5 * time.Second
+	done := make(chan GiftBombEvent, 1)
+	client.OnGiftBomb(func(channel string, bomb GiftBombEvent) {
+		done <- bomb
+	})
+	start := func() {
+		client.doCallbacks(createMessage("USERNOTICE", "#test", nil, map[string]string{
+			"msg-id":                    "submysterygift",
+			"msg-param-mass-gift-count": "3",
+			"msg-param-origin-id":       "abc",
+		}))
+	}
+
+	// The timeout follows the client's clock
+	start()
+	clock.step()
+	if bomb := <-done; bomb.OriginID != "abc" {
+		t.Errorf("Expected 'abc', got '%s'", bomb.OriginID)
+	}
+
+	// Gift bombs still incomplete when the connection closes are discarded
+	start()
+	<-clock.pending
+	client.Disconnect()
+	clock.Advance(time.Minute)
+	select {
+	case bomb := <-done:
+		t.Errorf("Expected no gift bomb, got '%+v'", bomb)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
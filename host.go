@@ -0,0 +1,105 @@
+package gotirc
+
+import (
+	"strconv"
+	"strings"
+)
+
+// OnHost adds an event callback for when a channel starts hosting another
+// channel. viewers is the number of viewers sent to the target, or 0 if the
+// server did not report it.
+func (c *Client) OnHost(callback func(channel, target string, viewers int)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.hostCallbacks = append(c.hostCallbacks, callback)
+}
+
+// OnUnhost adds an event callback for when a channel stops hosting
+func (c *Client) OnUnhost(callback func(channel string)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.unhostCallbacks = append(c.unhostCallbacks, callback)
+}
+
+// doHostTargetCallbacks handles HOSTTARGET messages, which have the form
+// HOSTTARGET #channel :<target|-> [viewers]
+func (c *Client) doHostTargetCallbacks(msg *Message) {
+	if len(msg.Params) < 2 {
+		return
+	}
+
+	fields := strings.Fields(msg.Params[1])
+	if len(fields) == 0 {
+		return
+	}
+
+	viewers := 0
+	if len(fields) > 1 {
+		viewers, _ = strconv.Atoi(fields[1])
+	}
+
+	if fields[0] == "-" {
+		c.setHostTarget(msg.Params[0], "", 0)
+	} else {
+		c.setHostTarget(msg.Params[0], fields[0], viewers)
+	}
+}
+
+// doHostNoticeCallbacks handles the host_on and host_off NOTICEs sent to the
+// hosting channel
+func (c *Client) doHostNoticeCallbacks(msg *Message) {
+	if len(msg.Params) < 1 {
+		return
+	}
+
+	switch NoticeID(msg.Tags["msg-id"]) {
+	case NoticeHostOn:
+		// Now hosting <target>.
+		if len(msg.Params) < 2 {
+			return
+		}
+		fields := strings.Fields(msg.Params[1])
+		if len(fields) == 0 {
+			return
+		}
+		c.setHostTarget(msg.Params[0], strings.TrimSuffix(fields[len(fields)-1], "."), 0)
+	case NoticeHostOff:
+		c.setHostTarget(msg.Params[0], "", 0)
+	}
+}
+
+// setHostTarget records the host target of a channel and runs the host callbacks
+// if it changed. An empty target means the channel is not hosting.
+func (c *Client) setHostTarget(channel, target string, viewers int) {
+	c.stateMu.Lock()
+	if c.hostTargets == nil {
+		c.hostTargets = make(map[string]string)
+	}
+	old := c.hostTargets[channel]
+	if target == "" {
+		delete(c.hostTargets, channel)
+	} else {
+		c.hostTargets[channel] = target
+	}
+	c.stateMu.Unlock()
+
+	if strings.EqualFold(old, target) {
+		return
+	}
+
+	c.callbackMu.Lock()
+	hostCallbacks := c.hostCallbacks
+	unhostCallbacks := c.unhostCallbacks
+	c.callbackMu.Unlock()
+
+	if target == "" {
+		for _, cb := range unhostCallbacks {
+			cb(channel)
+		}
+		return
+	}
+
+	for _, cb := range hostCallbacks {
+		cb(channel, target, viewers)
+	}
+}
@@ -0,0 +1,56 @@
+package gotirc
+
+import "testing"
+
+func TestOnHost(t *testing.T) {
+	client := NewClient(Options{})
+	expectedChan := "#test"
+	var gotChan, gotTarget string
+	var gotViewers int
+	hosts := 0
+	unhosts := 0
+	client.OnHost(func(channel, target string, viewers int) {
+		hosts++
+		gotChan, gotTarget, gotViewers = channel, target, viewers
+	})
+	client.OnUnhost(func(channel string) {
+		unhosts++
+		gotChan = channel
+	})
+
+	client.doCallbacks(":tmi.twitch.tv HOSTTARGET #test :target 42\r\n")
+
+	if hosts != 1 {
+		t.Errorf("Expected '1' call, got '%d'", hosts)
+	}
+	if expectedChan != gotChan {
+		t.Errorf("Expected '%s', got '%s'", expectedChan, gotChan)
+	}
+	if gotTarget != "target" {
+		t.Errorf("Expected 'target', got '%s'", gotTarget)
+	}
+	if gotViewers != 42 {
+		t.Errorf("Expected '42', got '%d'", gotViewers)
+	}
+
+	// NOTICE for the same host should not run the callbacks again
+	client.doCallbacks("@msg-id=host_on :tmi.twitch.tv NOTICE #test :Now hosting target.\r\n")
+	if hosts != 1 {
+		t.Errorf("Expected '1' call, got '%d'", hosts)
+	}
+
+	client.doCallbacks(":tmi.twitch.tv HOSTTARGET #test :- 0\r\n")
+	client.doCallbacks("@msg-id=host_off :tmi.twitch.tv NOTICE #test :Exited host mode.\r\n")
+	if unhosts != 1 {
+		t.Errorf("Expected '1' call, got '%d'", unhosts)
+	}
+
+	// Host reported only by NOTICE
+	client.doCallbacks("@msg-id=host_on :tmi.twitch.tv NOTICE #test :Now hosting other.\r\n")
+	if hosts != 2 {
+		t.Errorf("Expected '2' calls, got '%d'", hosts)
+	}
+	if gotTarget != "other" || gotViewers != 0 {
+		t.Errorf("Expected 'other' and '0', got '%s' and '%d'", gotTarget, gotViewers)
+	}
+}
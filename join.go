@@ -0,0 +1,152 @@
+package gotirc
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrJoinTimeout is reported when the server doesn't confirm a JOIN in time
+var ErrJoinTimeout = errors.New("join timed out")
+
+// maxJoinLength is the maximum length of a batched JOIN command, leaving room
+// for the line ending within the 512 byte IRC line limit
+const maxJoinLength = 500
+
+// OnJoinResult adds an event callback for the outcome of joining a channel. err
+// is nil when the server confirms the JOIN, a *NoticeError when the server
+// rejects it (e.g., the channel is suspended), or ErrJoinTimeout when the server
+// doesn't respond in time. Channels in Options.Channels that can't be joined are
+// reported too, e.g., with ErrInvalidChannel. Timeouts are reported on a timer
+// goroutine, concurrently with the other callbacks. JOINs still pending when the
+// connection closes are not reported.
+func (c *Client) OnJoinResult(callback func(channel string, err error)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.joinResultCallbacks = append(c.joinResultCallbacks, callback)
+}
+
+// pendingJoin is a JOIN the server hasn't confirmed or rejected yet
+type pendingJoin struct {
+	stop func() // Stops the join timeout
+}
+
+// joinChannels returns the channels of a JOIN command, or nil if data is not a
+// JOIN command
+func joinChannels(data string) []string {
+	msg := NewMessage(data)
+	if msg.Command != "JOIN" || len(msg.Params) < 1 {
+		return nil
+	}
+	return strings.Split(msg.Params[0], ",")
+}
+
+// nextJoinBatch returns a JOIN command for up to max of the channels, the
+// channels it joins and the remaining channels
+func nextJoinBatch(channels []string, max int) (string, []string, []string) {
+	n := 0
+	length := len("JOIN ")
+	for n < len(channels) && n < max {
+		l := len(channels[n])
+		if n > 0 {
+			l++ // Comma
+		}
+		if n > 0 && length+l > maxJoinLength {
+			break
+		}
+		length += l
+		n++
+	}
+	return "JOIN " + strings.Join(channels[:n], ","), channels[:n], channels[n:]
+}
+
+// joinStarted records that JOINs were sent for the channels, reporting
+// ErrJoinTimeout for those the server hasn't confirmed within the join timeout
+func (c *Client) joinStarted(channels []string) {
+	if c.parent != nil {
+		c.parent.joinStarted(channels)
+		return
+	}
+
+	c.joinsMu.Lock()
+	defer c.joinsMu.Unlock()
+	if c.pendingJoins == nil {
+		c.pendingJoins = make(map[string]*pendingJoin)
+	}
+	for _, channel := range channels {
+		channel := channel
+		if old, ok := c.pendingJoins[channel]; ok {
+			old.stop()
+		}
+		join := &pendingJoin{}
+		join.stop = c.afterFunc(c.joinTimeout, func() {
+			c.joinTimedOut(channel, join)
+		})
+		c.pendingJoins[channel] = join
+	}
+}
+
+// joinTimedOut reports ErrJoinTimeout for a channel if join is still the
+// channel's pending JOIN
+func (c *Client) joinTimedOut(channel string, join *pendingJoin) {
+	c.joinsMu.Lock()
+	current := c.pendingJoins[channel] == join
+	c.joinsMu.Unlock()
+
+	if current {
+		c.joinFinished(channel, ErrJoinTimeout)
+	}
+}
+
+// discardPendingJoins forgets the pending JOINs and stops their timers
+func (c *Client) discardPendingJoins() {
+	c.joinsMu.Lock()
+	defer c.joinsMu.Unlock()
+	for _, join := range c.pendingJoins {
+		join.stop()
+	}
+	c.pendingJoins = make(map[string]*pendingJoin)
+}
+
+// joinFinished reports the outcome of joining a channel to JoinAndWait and, if a
+// JOIN is pending, to the join result callbacks
+func (c *Client) joinFinished(channel string, err error) {
+	c.joinsMu.Lock()
+	join, ok := c.pendingJoins[channel]
+	if ok {
+		join.stop()
+		delete(c.pendingJoins, channel)
+	}
+	waiters := c.joinWaiters[channel]
+	delete(c.joinWaiters, channel)
+	c.joinsMu.Unlock()
+
+	for _, result := range waiters {
+		result <- err
+	}
+	if ok {
+		c.doJoinResultCallbacks(channel, err)
+	}
+}
+
+func (c *Client) doJoinResultCallbacks(channel string, err error) {
+	c.callbackMu.Lock()
+	callbacks := c.joinResultCallbacks
+	c.callbackMu.Unlock()
+
+	for _, cb := range callbacks {
+		cb(channel, err)
+	}
+}
+
+// isSelf returns true if nick is the client's own nick, or the nick of its read
+// connection
+func (c *Client) isSelf(nick string) bool {
+	return c.isOwnNick(nick) || c.isReader(nick)
+}
+
+// isOwnNick returns true if nick is the nick of the client's own connection
+func (c *Client) isOwnNick(nick string) bool {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	return c.nick != "" && strings.EqualFold(c.nick, nick)
+}
@@ -0,0 +1,216 @@
+package gotirc
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNextJoinBatch(t *testing.T) {
+	channels := []string{"#a", "#b", "#c"}
+	data, batch, rest := nextJoinBatch(channels, 2)
+	if data != "JOIN #a,#b" || len(batch) != 2 || len(rest) != 1 {
+		t.Errorf("Expected 'JOIN #a,#b', got '%s' (%v, %v)", data, batch, rest)
+	}
+
+	data, _, rest = nextJoinBatch(channels, 10)
+	if data != "JOIN #a,#b,#c" || len(rest) != 0 {
+		t.Errorf("Expected 'JOIN #a,#b,#c', got '%s'", data)
+	}
+
+	// Batches are limited in length
+	channels = nil
+	for i := 0; i < 100; i++ {
+		channels = append(channels, fmt.Sprintf("#channel%02d", i))
+	}
+	data, batch, rest = nextJoinBatch(channels, 100)
+	if len(data) > maxJoinLength {
+		t.Errorf("Expected length <= %d, got %d", maxJoinLength, len(data))
+	}
+	if len(batch)+len(rest) != 100 {
+		t.Errorf("Expected '100' channels, got '%d'", len(batch)+len(rest))
+	}
+}
+
+func TestJoinLimiter(t *testing.T) {
+	var wg sync.WaitGroup
+	client, server := createClientServer()
+	clock := newFakeClock()
+	client.options.Clock = clock
+	client.options.JoinRateLimit = RateLimit{Messages: 2, Per: time.Second}
+	client.sendQueue = make(chan string, sendBufferSize)
+	client.state = StateConnected
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		client.startSendLoop(10, 1)
+	}()
+
+	lines := readLines(server)
+	for _, channel := range []string{"a", "b", "c", "d"} {
+		client.Join(channel)
+	}
+	client.Say("a", "test")
+
+	start := clock.Now()
+	var sent []string
+	for i := 0; i < 4; i++ {
+		if i >= 2 {
+			clock.step()
+		}
+		sent = append(sent, strings.TrimSpace(<-lines))
+	}
+	delta := clock.Now().Sub(start)
+
+	// The first batch is sent immediately, messages aren't held up by JOINs and
+	// the remaining channels are joined as the limit allows
+	expected := []string{"JOIN #a,#b", "PRIVMSG #a :test", "JOIN #c", "JOIN #d"}
+	for i := range expected {
+		if sent[i] != expected[i] {
+			t.Errorf("Expected '%s', got '%s'", expected[i], sent[i])
+		}
+	}
+	if delta != time.Second {
+		t.Errorf("Expected delta 1s, got %s", delta)
+	}
+
+	server.Close()
+	client.Say("a", "X")
+	clock.wait(&wg)
+}
+
+func TestOnJoinResult(t *testing.T) {
+	client := NewClient(Options{})
+	client.nick = "test_nick"
+	client.joinTimeout = 50 * time.Millisecond
+	results := make(chan error, 3)
+	var gotChans []string
+	var mu sync.Mutex
+	client.OnJoinResult(func(channel string, err error) {
+		mu.Lock()
+		gotChans = append(gotChans, channel)
+		mu.Unlock()
+		results <- err
+	})
+
+	client.joinStarted([]string{"#joined", "#suspended", "#silent"})
+
+	// Other users joining don't confirm the JOIN
+	client.doCallbacks(":other!other@other.tmi.twitch.tv JOIN #joined\r\n")
+	client.doCallbacks(":test_nick!test_nick@test_nick.tmi.twitch.tv JOIN #joined\r\n")
+	if err := <-results; err != nil {
+		t.Errorf("Expected 'nil', got '%s'", err)
+	}
+
+	client.doCallbacks("@msg-id=msg_channel_suspended :tmi.twitch.tv NOTICE #suspended :This channel does not exist or has been suspended.\r\n")
+	if err, ok := (<-results).(*NoticeError); !ok || err.MsgID != NoticeChannelSuspended {
+		t.Errorf("Expected '*NoticeError', got '%v'", err)
+	}
+
+	select {
+	case err := <-results:
+		if err != ErrJoinTimeout {
+			t.Errorf("Expected '%s', got '%v'", ErrJoinTimeout, err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected join timeout")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{"#joined", "#suspended", "#silent"}
+	if strings.Join(gotChans, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected '%v', got '%v'", expected, gotChans)
+	}
+}
+
+func TestJoinTimeoutDiscarded(t *testing.T) {
+	client, server := createClientServer()
+	defer server.Close()
+	clock := newFakeClock()
+	client.options.Clock = clock
+	client.state = StateConnected
+	client.joinTimeout = 5 * time.Second
+	results := make(chan string, 2)
+	client.OnJoinResult(func(channel string, err error) {
+		results <- channel
+	})
+
+	// The timeout follows the client's clock
+	client.joinStarted([]string{"#timeout"})
+	clock.step()
+	if channel := <-results; channel != "#timeout" {
+		t.Errorf("Expected '#timeout', got '%s'", channel)
+	}
+
+	// Timeouts pending when the connection closes are discarded
+	client.joinStarted([]string{"#stale"})
+	<-clock.pending
+	client.Disconnect()
+	clock.Advance(time.Minute)
+	select {
+	case channel := <-results:
+		t.Errorf("Expected no join result, got '%s'", channel)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestConnectJoinsManyChannels(t *testing.T) {
+	joined := make(chan string, 1000)
+	port := startTestServer(t, func(conn net.Conn, line string) {
+		for _, channel := range joinChannels(line) {
+			joined <- channel
+		}
+	})
+
+	// More channels than the send queue holds
+	channels := []string{"#invalid channel"}
+	for i := 0; i < 2*sendBufferSize; i++ {
+		channels = append(channels, fmt.Sprintf("channel%d", i))
+	}
+	client := NewClient(Options{
+		Host:          "127.0.0.1",
+		Port:          port,
+		Channels:      channels,
+		JoinRateLimit: RateLimit{Messages: 10000, Per: time.Second},
+	})
+	results := make(chan error, 1)
+	client.OnJoinResult(func(channel string, err error) {
+		if channel == "#invalid channel" {
+			results <- err
+		}
+	})
+
+	done := make(chan error)
+	go func() {
+		done <- client.Connect(username, password)
+	}()
+	defer func() {
+		client.Disconnect()
+		<-done
+	}()
+
+	// The malformed channel is reported, and every other channel is joined
+	select {
+	case err := <-results:
+		if err != ErrInvalidChannel {
+			t.Errorf("Expected '%s', got '%v'", ErrInvalidChannel, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the malformed channel to be reported")
+	}
+	seen := make(map[string]bool)
+	timeout := time.After(5 * time.Second)
+	for len(seen) < 2*sendBufferSize {
+		select {
+		case channel := <-joined:
+			seen[channel] = true
+		case <-timeout:
+			t.Fatalf("Expected %d channels to be joined, got %d", 2*sendBufferSize, len(seen))
+		}
+	}
+}
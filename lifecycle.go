@@ -0,0 +1,127 @@
+package gotirc
+
+// State is the state of a Client's connection with the server
+type State int
+
+// The states of a connection. A Client starts out Disconnected, and Connect
+// moves it through Connecting (dialing the server) and Authenticating (waiting
+// for the server to accept the nick and pass) to Connected. Disconnect, Shutdown
+// or a connection error move it to Closing, and it is Disconnected again once the
+// connection is closed and Connect has returned.
+const (
+	StateDisconnected State = iota
+	StateConnecting
+	StateAuthenticating
+	StateConnected
+	StateClosing
+)
+
+func (s State) String() string {
+	switch s {
+	case StateDisconnected:
+		return "Disconnected"
+	case StateConnecting:
+		return "Connecting"
+	case StateAuthenticating:
+		return "Authenticating"
+	case StateConnected:
+		return "Connected"
+	case StateClosing:
+		return "Closing"
+	}
+	return "Unknown"
+}
+
+// OnStateChange adds an event callback for when the state of the client's
+// connection changes
+func (c *Client) OnStateChange(callback func(state State)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.stateChangeCallbacks = append(c.stateChangeCallbacks, callback)
+}
+
+// State returns the state of the client's connection with the server
+func (c *Client) State() State {
+	c.connectedMu.RLock()
+	defer c.connectedMu.RUnlock()
+	return c.state
+}
+
+// setState changes the state of the connection and runs the state change
+// callbacks. If from is given, the state is only changed if it is currently one
+// of from, and false is returned otherwise.
+func (c *Client) setState(to State, from ...State) bool {
+	c.connectedMu.Lock()
+	if !c.stateIn(from...) {
+		c.connectedMu.Unlock()
+		return false
+	}
+	c.changeState(to)
+	c.connectedMu.Unlock()
+
+	c.deliverStateChanges()
+	return true
+}
+
+// changeState changes the state of the connection and queues the change for
+// deliverStateChanges. It must be called with c.connectedMu held.
+func (c *Client) changeState(to State) {
+	if c.state != to {
+		c.state = to
+		c.stateChanges = append(c.stateChanges, to)
+	}
+}
+
+// deliverStateChanges runs the state change callbacks for the queued changes.
+// The changes are delivered by one goroutine at a time so that the callbacks see
+// them in order, even if a callback changes the state.
+func (c *Client) deliverStateChanges() {
+	c.connectedMu.Lock()
+	defer c.connectedMu.Unlock()
+	if c.deliveringStates {
+		return
+	}
+	c.deliveringStates = true
+	for len(c.stateChanges) > 0 {
+		state := c.stateChanges[0]
+		c.stateChanges = c.stateChanges[1:]
+		c.connectedMu.Unlock()
+		c.doStateChangeCallbacks(state)
+		c.connectedMu.Lock()
+	}
+	c.deliveringStates = false
+}
+
+// stateIn returns true if the state is one of states, or if no states are given.
+// It must be called with c.connectedMu held.
+func (c *Client) stateIn(states ...State) bool {
+	if len(states) == 0 {
+		return true
+	}
+	for _, s := range states {
+		if c.state == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Client) doStateChangeCallbacks(state State) {
+	c.callbackMu.Lock()
+	callbacks := c.stateChangeCallbacks
+	c.callbackMu.Unlock()
+
+	for _, cb := range callbacks {
+		cb(state)
+	}
+}
+
+// isClosed returns true if ch has been closed
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
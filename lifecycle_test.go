@@ -0,0 +1,116 @@
+package gotirc
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestStateString(t *testing.T) {
+	if s := StateAuthenticating.String(); s != "Authenticating" {
+		t.Errorf("Expected 'Authenticating', got '%s'", s)
+	}
+	if s := State(42).String(); s != "Unknown" {
+		t.Errorf("Expected 'Unknown', got '%s'", s)
+	}
+}
+
+// startTestServer starts a server that welcomes each connection and passes the
+// lines it receives afterwards to handle. It returns the server's port.
+func startTestServer(t *testing.T, handle func(conn net.Conn, line string)) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				in := bufio.NewReader(conn)
+				in.ReadString('\n') // PASS
+				in.ReadString('\n') // NICK
+				conn.Write([]byte(":tmi.twitch.tv 001 " + username + " :Welcome, GLHF!\r\n"))
+				for {
+					line, err := in.ReadString('\n')
+					if err != nil {
+						return
+					}
+					handle(conn, strings.TrimSpace(line))
+				}
+			}()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestReconnect(t *testing.T) {
+	port := startTestServer(t, func(conn net.Conn, line string) {})
+	client := NewClient(Options{Host: "127.0.0.1", Port: port})
+
+	var mu sync.Mutex
+	var states []State
+	connected := make(chan struct{}, 1)
+	client.OnStateChange(func(state State) {
+		mu.Lock()
+		states = append(states, state)
+		mu.Unlock()
+		if state == StateConnected {
+			connected <- struct{}{}
+		}
+	})
+
+	// Sending while the client connects and disconnects must neither race nor
+	// panic
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				client.Say("test", "test")
+			}
+		}
+	}()
+
+	for i := 0; i < 3; i++ {
+		done := make(chan error)
+		go func() {
+			done <- client.Connect(username, password)
+		}()
+		<-connected
+		if !client.Connected() {
+			t.Error("Expected 'true', got 'false'")
+		}
+		client.Disconnect()
+		<-done
+		if state := client.State(); state != StateDisconnected {
+			t.Errorf("Expected '%s', got '%s'", StateDisconnected, state)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	cycle := []State{StateConnecting, StateAuthenticating, StateConnected, StateClosing, StateDisconnected}
+	var expected []State
+	for i := 0; i < 3; i++ {
+		expected = append(expected, cycle...)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(states, expected) {
+		t.Errorf("Expected '%v', got '%v'", expected, states)
+	}
+}
@@ -1,7 +1,10 @@
 // Package gotirc contains functions for connecting to Twitch.tv chat via IRC
 package gotirc
 
-import "strings"
+import (
+	"sort"
+	"strings"
+)
 
 // Message holds data received from the server
 type Message struct {
@@ -130,3 +133,79 @@ func NewMessage(message string) Message {
 	}
 	return msg
 }
+
+var tagEscaper = strings.NewReplacer(`\`, `\\`, ";", `\:`, " ", `\s`, "\r", `\r`, "\n", `\n`)
+
+// escapeTagValue escapes a tag value according to the IRCv3 message tags spec
+func escapeTagValue(value string) string {
+	return tagEscaper.Replace(value)
+}
+
+// unescapeTagValue reverses escapeTagValue. Tag values in a Message are left
+// escaped as received from the server.
+func unescapeTagValue(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			b.WriteByte(value[i])
+			continue
+		}
+		if i+1 == len(value) {
+			break // A trailing backslash is dropped
+		}
+		i++
+		switch value[i] {
+		case ':':
+			b.WriteByte(';')
+		case 's':
+			b.WriteByte(' ')
+		case 'r':
+			b.WriteByte('\r')
+		case 'n':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
+
+// validTagKey returns true if key is a valid IRCv3 tag key, which has the form
+// [+][vendor/]name, where the vendor is a host name and the name consists of
+// letters, digits and hyphens
+func validTagKey(key string) bool {
+	key = strings.TrimPrefix(key, "+")
+	if i := strings.LastIndexByte(key, '/'); i >= 0 {
+		vendor := key[:i]
+		if vendor == "" || strings.Trim(vendor, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-.") != "" {
+			return false
+		}
+		key = key[i+1:]
+	}
+	return key != "" && strings.Trim(key, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-") == ""
+}
+
+// formatTags formats tags as the tag component of an outbound message (e.g.,
+// @key1=value1;key2=value2), sorted by key and without a trailing space
+func formatTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for i, k := range keys {
+		if i == 0 {
+			b.WriteByte('@')
+		} else {
+			b.WriteByte(';')
+		}
+		b.WriteString(k)
+		if v := tags[k]; v != "" {
+			b.WriteByte('=')
+			b.WriteString(escapeTagValue(v))
+		}
+	}
+	return b.String()
+}
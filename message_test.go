@@ -209,3 +209,40 @@ func TestCommandOnly(t *testing.T) {
 		t.Errorf(`Expcted %s, got %s`, raw, msg.Raw)
 	}
 }
+
+func TestTagEscaping(t *testing.T) {
+	value := "a b;c\\d\r\ne"
+	escaped := escapeTagValue(value)
+	if escaped != `a\sb\:c\\d\r\ne` {
+		t.Errorf(`Expected 'a\sb\:c\\d\r\ne', got '%s'`, escaped)
+	}
+	if unescaped := unescapeTagValue(escaped); unescaped != value {
+		t.Errorf(`Expected '%q', got '%q'`, value, unescaped)
+	}
+	if unescaped := unescapeTagValue(`a\b\`); unescaped != "ab" {
+		t.Errorf(`Expected 'ab', got '%s'`, unescaped)
+	}
+}
+
+func TestFormatTags(t *testing.T) {
+	tags := formatTags(map[string]string{"b": "x y", "a": "1", "c": ""})
+	if tags != `@a=1;b=x\sy;c` {
+		t.Errorf(`Expected '@a=1;b=x\sy;c', got '%s'`, tags)
+	}
+	if tags := formatTags(nil); tags != "" {
+		t.Errorf(`Expected '', got '%s'`, tags)
+	}
+}
+
+func TestValidTagKey(t *testing.T) {
+	for _, key := range []string{"client-nonce", "+draft-reply", "+example.com/foo-bar", "a1"} {
+		if !validTagKey(key) {
+			t.Errorf("Expected '%s' to be valid", key)
+		}
+	}
+	for _, key := range []string{"", "+", "a b", "a;b", "a=b", "a\r\nPRIVMSG #other :spam", "/foo", "ex_ample.com/foo", "foo/"} {
+		if validTagKey(key) {
+			t.Errorf("Expected '%s' to be invalid", key)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package gotirc
+
+import "strings"
+
+// OnModeChange adds an event callback for when a user gains or loses moderator
+// status in a channel, as reported by MODE messages or the badges of the user's
+// messages
+func (c *Client) OnModeChange(callback func(channel, username string, mod bool)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.modeCallbacks = append(c.modeCallbacks, callback)
+}
+
+// IsModerator returns true if the user is known to be a moderator (or the
+// broadcaster) of a channel. If the "#" prefix is missing, it is automatically
+// prepended.
+func (c *Client) IsModerator(channel, username string) bool {
+	channel = channelName(channel)
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	_, ok := c.moderators[channel][strings.ToLower(username)]
+	return ok
+}
+
+// IsVIP returns true if the user is known to be a VIP of a channel. If the "#"
+// prefix is missing, it is automatically prepended.
+func (c *Client) IsVIP(channel, username string) bool {
+	channel = channelName(channel)
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	_, ok := c.vips[channel][strings.ToLower(username)]
+	return ok
+}
+
+// Moderators returns the sorted usernames of the known moderators of a channel.
+// If the "#" prefix is missing, it is automatically prepended.
+func (c *Client) Moderators(channel string) []string {
+	channel = channelName(channel)
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	return sortedUsers(c.moderators[channel])
+}
+
+// doModeCallbacks handles MODE messages, which have the form
+// MODE #channel <+o|-o> <username>
+func (c *Client) doModeCallbacks(msg *Message) {
+	if len(msg.Params) < 3 {
+		return
+	}
+
+	switch msg.Params[1] {
+	case "+o":
+		c.setModerator(msg.Params[0], msg.Params[2], true)
+	case "-o":
+		c.setModerator(msg.Params[0], msg.Params[2], false)
+	}
+}
+
+// updateBadgeRoles updates the moderator and VIP sets of a channel from the
+// badges of a message sent by a user
+func (c *Client) updateBadgeRoles(msg *Message) {
+	if msg.Prefix.Nick == "" || len(msg.Params) < 1 {
+		return
+	}
+	if _, ok := msg.Tags["badges"]; !ok {
+		return
+	}
+
+	badges := parseBadges(msg.Tags["badges"])
+	_, moderator := badges["moderator"]
+	_, broadcaster := badges["broadcaster"]
+	_, vip := badges["vip"]
+	channel := msg.Params[0]
+
+	c.stateMu.Lock()
+	if vip {
+		addChannelUser(&c.vips, channel, msg.Prefix.Nick)
+	} else {
+		removeChannelUser(c.vips, channel, msg.Prefix.Nick)
+	}
+	c.stateMu.Unlock()
+
+	c.setModerator(channel, msg.Prefix.Nick, moderator || broadcaster || msg.Tags["mod"] == "1")
+}
+
+// setModerator records the moderator status of a user and runs the mode
+// callbacks if it changed
+func (c *Client) setModerator(channel, username string, mod bool) {
+	c.stateMu.Lock()
+	var changed bool
+	if mod {
+		changed = addChannelUser(&c.moderators, channel, username)
+	} else {
+		changed = removeChannelUser(c.moderators, channel, username)
+	}
+	c.stateMu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	c.callbackMu.Lock()
+	callbacks := c.modeCallbacks
+	c.callbackMu.Unlock()
+
+	for _, cb := range callbacks {
+		cb(channel, strings.ToLower(username), mod)
+	}
+}
@@ -0,0 +1,86 @@
+package gotirc
+
+import "testing"
+
+func TestOnModeChange(t *testing.T) {
+	client := NewClient(Options{})
+	expectedChan := "#test"
+	var gotChan, gotUser string
+	var gotMod bool
+	calls := 0
+	client.OnModeChange(func(channel, username string, mod bool) {
+		calls++
+		gotChan, gotUser, gotMod = channel, username, mod
+	})
+
+	client.doCallbacks(":jtv MODE #test +o test_mod\r\n")
+
+	if calls != 1 {
+		t.Errorf("Expected '1' call, got '%d'", calls)
+	}
+	if expectedChan != gotChan || gotUser != "test_mod" || !gotMod {
+		t.Errorf("Expected '%s test_mod true', got '%s %s %t'", expectedChan, gotChan, gotUser, gotMod)
+	}
+	if !client.IsModerator("test", "Test_Mod") {
+		t.Error("Expected 'true', got 'false'")
+	}
+
+	// Already known
+	client.doCallbacks(":jtv MODE #test +o test_mod\r\n")
+	if calls != 1 {
+		t.Errorf("Expected '1' call, got '%d'", calls)
+	}
+
+	client.doCallbacks(":jtv MODE #test -o test_mod\r\n")
+	if calls != 2 || gotMod {
+		t.Errorf("Expected '2' calls, got '%d'", calls)
+	}
+	if client.IsModerator(expectedChan, "test_mod") {
+		t.Error("Expected 'false', got 'true'")
+	}
+}
+
+func TestBadgeRoles(t *testing.T) {
+	client := NewClient(Options{})
+	expectedChan := "#test"
+	calls := 0
+	client.OnModeChange(func(channel, username string, mod bool) {
+		calls++
+	})
+
+	// createMessage uses "x" as the sender
+	line := createMessage("PRIVMSG", expectedChan, []string{"hi"}, map[string]string{"badges": "moderator/1", "mod": "1"})
+	client.doCallbacks(line)
+	line = createMessage("PRIVMSG", expectedChan, []string{"hi"}, map[string]string{"badges": "moderator/1,subscriber/3", "mod": "1"})
+	client.doCallbacks(line)
+
+	if !client.IsModerator(expectedChan, "x") {
+		t.Error("Expected 'true', got 'false'")
+	}
+	if calls != 1 {
+		t.Errorf("Expected '1' call, got '%d'", calls)
+	}
+	if mods := client.Moderators(expectedChan); len(mods) != 1 || mods[0] != "x" {
+		t.Errorf("Expected '[x]', got '%v'", mods)
+	}
+
+	line = createMessage("PRIVMSG", expectedChan, []string{"hi"}, map[string]string{"badges": "vip/1", "mod": "0"})
+	client.doCallbacks(line)
+
+	if client.IsModerator(expectedChan, "x") {
+		t.Error("Expected 'false', got 'true'")
+	}
+	if !client.IsVIP(expectedChan, "x") {
+		t.Error("Expected 'true', got 'false'")
+	}
+	if calls != 2 {
+		t.Errorf("Expected '2' calls, got '%d'", calls)
+	}
+
+	line = createMessage("PRIVMSG", expectedChan, []string{"hi"}, map[string]string{"badges": "", "mod": "0"})
+	client.doCallbacks(line)
+
+	if client.IsVIP(expectedChan, "x") {
+		t.Error("Expected 'false', got 'true'")
+	}
+}
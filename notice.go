@@ -0,0 +1,111 @@
+package gotirc
+
+import "strings"
+
+// NoticeID identifies the kind of a NOTICE sent by the server (the msg-id tag)
+type NoticeID string
+
+// Known NOTICE msg-ids. Twitch may send others at any time, so callbacks should
+// be prepared to receive values not listed here.
+const (
+	NoticeRateLimit              NoticeID = "msg_ratelimit"
+	NoticeDuplicate              NoticeID = "msg_duplicate"
+	NoticeBanned                 NoticeID = "msg_banned"
+	NoticeTimedOut               NoticeID = "msg_timedout"
+	NoticeChannelSuspended       NoticeID = "msg_channel_suspended"
+	NoticeChannelBlocked         NoticeID = "msg_channel_blocked"
+	NoticeSuspended              NoticeID = "msg_suspended"
+	NoticeFollowersOnly          NoticeID = "msg_followersonly"
+	NoticeFollowersOnlyZero      NoticeID = "msg_followersonly_zero"
+	NoticeSlowMode               NoticeID = "msg_slowmode"
+	NoticeSubsOnly               NoticeID = "msg_subsonly"
+	NoticeEmoteOnly              NoticeID = "msg_emoteonly"
+	NoticeR9K                    NoticeID = "msg_r9k"
+	NoticeRejected               NoticeID = "msg_rejected"
+	NoticeRejectedMandatory      NoticeID = "msg_rejected_mandatory"
+	NoticeVerifiedEmail          NoticeID = "msg_verified_email"
+	NoticeRequiresVerifiedPhone  NoticeID = "msg_requires_verified_phone_number"
+	NoticeUnrecognizedCommand    NoticeID = "unrecognized_cmd"
+	NoticeNoPermission           NoticeID = "no_permission"
+	NoticeWhisperRestricted      NoticeID = "whisper_restricted"
+	NoticeWhisperRestrictedRecip NoticeID = "whisper_restricted_recipient"
+	NoticeEmoteOnlyOn            NoticeID = "emote_only_on"
+	NoticeEmoteOnlyOff           NoticeID = "emote_only_off"
+	NoticeFollowersOn            NoticeID = "followers_on"
+	NoticeFollowersOnZero        NoticeID = "followers_on_zero"
+	NoticeFollowersOff           NoticeID = "followers_off"
+	NoticeR9KOn                  NoticeID = "r9k_on"
+	NoticeR9KOff                 NoticeID = "r9k_off"
+	NoticeSlowOn                 NoticeID = "slow_on"
+	NoticeSlowOff                NoticeID = "slow_off"
+	NoticeSubsOn                 NoticeID = "subs_on"
+	NoticeSubsOff                NoticeID = "subs_off"
+	NoticeHostOn                 NoticeID = "host_on"
+	NoticeHostOff                NoticeID = "host_off"
+)
+
+// IsRejection returns true if the NOTICE reports that a message sent by the
+// client was not delivered (e.g., msg_ratelimit, msg_duplicate, msg_banned) or
+// that a command was refused (e.g., no_permission, unrecognized_cmd, or the
+// bad_ and usage_ msg-ids of commands with invalid arguments)
+func (id NoticeID) IsRejection() bool {
+	switch id {
+	case NoticeUnrecognizedCommand, NoticeNoPermission, NoticeWhisperRestricted, NoticeWhisperRestrictedRecip:
+		return true
+	}
+	for _, prefix := range []string{"msg_", "bad_", "usage_"} {
+		if strings.HasPrefix(string(id), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// OnNotice adds an event callback for when the server sends a NOTICE, such as
+// when a message sent by the client was rejected. msgID is empty if the NOTICE
+// had no msg-id tag (e.g., a failed login).
+func (c *Client) OnNotice(callback func(channel string, msgID NoticeID, text string)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.noticeCallbacks = append(c.noticeCallbacks, callback)
+}
+
+func (c *Client) doNoticeCallbacks(msg *Message) {
+	c.callbackMu.Lock()
+	callbacks := c.noticeCallbacks
+	c.callbackMu.Unlock()
+
+	var channel, text string
+	if len(msg.Params) > 0 {
+		channel = msg.Params[0]
+	}
+	if len(msg.Params) > 1 {
+		text = msg.Params[1]
+	}
+
+	msgID := NoticeID(msg.Tags["msg-id"])
+	for _, cb := range callbacks {
+		cb(channel, msgID, text)
+	}
+
+	if msgID.IsRejection() {
+		c.pendingSays.resolve(channel, msg.Tags["client-nonce"], &NoticeError{
+			Channel: channel,
+			MsgID:   msgID,
+			Text:    text,
+		})
+	}
+
+	if msgID == NoticeChannelSuspended || msgID == NoticeChannelBlocked {
+		c.selfParted(channel)
+		c.joinFinished(channel, &NoticeError{
+			Channel: channel,
+			MsgID:   msgID,
+			Text:    text,
+		})
+	}
+
+	if msgID == NoticeHostOn || msgID == NoticeHostOff {
+		c.doHostNoticeCallbacks(msg)
+	}
+}
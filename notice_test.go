@@ -0,0 +1,56 @@
+package gotirc
+
+import "testing"
+
+func TestOnNotice(t *testing.T) {
+	client := NewClient(Options{})
+	expectedChan := "#test"
+	expectedText := "You are sending messages too quickly."
+	var gotChan string
+	var gotID NoticeID
+	var gotText string
+	client.OnNotice(func(channel string, msgID NoticeID, text string) {
+		gotChan, gotID, gotText = channel, msgID, text
+	})
+
+	line := createMessage("NOTICE", expectedChan, []string{expectedText}, map[string]string{"msg-id": "msg_ratelimit"})
+	client.doCallbacks(line)
+
+	if expectedChan != gotChan {
+		t.Errorf("Expected '%s', got '%s'", expectedChan, gotChan)
+	}
+	if gotID != NoticeRateLimit {
+		t.Errorf("Expected '%s', got '%s'", NoticeRateLimit, gotID)
+	}
+	if expectedText != gotText {
+		t.Errorf("Expected '%s', got '%s'", expectedText, gotText)
+	}
+
+	// Without msg-id
+	client.doCallbacks(":tmi.twitch.tv NOTICE * :Login authentication failed\r\n")
+
+	if gotChan != "*" {
+		t.Errorf("Expected '*', got '%s'", gotChan)
+	}
+	if gotID != "" {
+		t.Errorf("Expected '', got '%s'", gotID)
+	}
+	if gotText != "Login authentication failed" {
+		t.Errorf("Expected 'Login authentication failed', got '%s'", gotText)
+	}
+}
+
+func TestNoticeIsRejection(t *testing.T) {
+	rejections := []NoticeID{NoticeDuplicate, NoticeSlowMode, NoticeNoPermission, NoticeUnrecognizedCommand,
+		NoticeWhisperRestricted, "bad_ban_self", "usage_ban"}
+	for _, id := range rejections {
+		if !id.IsRejection() {
+			t.Errorf("Expected '%s' to be a rejection", id)
+		}
+	}
+	for _, id := range []NoticeID{NoticeSlowOn, NoticeHostOn, ""} {
+		if id.IsRejection() {
+			t.Errorf("Expected '%s' not to be a rejection", id)
+		}
+	}
+}
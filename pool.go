@@ -0,0 +1,461 @@
+package gotirc
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultChannelsPerConnection is the number of channels each connection of a
+// Pool joins unless told otherwise
+const DefaultChannelsPerConnection = 50
+
+// maxReconnectDelay is the longest a Pool waits before reconnecting a connection
+// that keeps failing
+const maxReconnectDelay = 5 * time.Minute
+
+// Pool spreads channels across as many connections to the server as needed for
+// each connection to join at most a limited number of channels. When a
+// connection drops, its channels are moved to the connected connections with
+// room to spare, and the connection reconnects to join the channels that didn't
+// fit. The delay before reconnecting doubles with every failed attempt in a row,
+// up to five minutes. A connection is released once it has no channels left.
+//
+// Every connection is a Client with its own rate limits. Twitch limits the
+// messages sent by an account across all of its connections, so a pool that
+// sends many messages should divide Options.RateLimit among its connections.
+type Pool struct {
+	options        Options
+	capacity       int
+	reconnectDelay time.Duration
+
+	mu        sync.Mutex
+	nick      string
+	pass      string
+	running   bool
+	closed    bool
+	err       error
+	done      chan struct{}
+	wg        sync.WaitGroup
+	conns     []*poolConn
+	channels  map[string]*poolConn
+	configure []func(client *Client)
+}
+
+// poolConn is one of the connections of a Pool and the channels assigned to it
+type poolConn struct {
+	client    *Client
+	channels  map[string]struct{}
+	started   bool
+	connected bool          // Whether the client connected since its last attempt
+	backoff   time.Duration // The delay before the client's last attempt
+	removed   bool          // Whether the connection was released by the pool
+}
+
+// NewPool returns a new Pool whose connections use the given options. Each
+// connection joins at most channelsPerConnection channels, or
+// DefaultChannelsPerConnection if it is not positive. The channels in
+// o.Channels are joined when the pool connects.
+func NewPool(o Options, channelsPerConnection int) *Pool {
+	if channelsPerConnection <= 0 {
+		channelsPerConnection = DefaultChannelsPerConnection
+	}
+	return &Pool{
+		options:        o,
+		capacity:       channelsPerConnection,
+		reconnectDelay: 5 * time.Second,
+		channels:       make(map[string]*poolConn),
+	}
+}
+
+// Connect connects the pool's connections to the server specified in the options
+// and uses the supplied nick and pass (oauth token) to authenticate. Connect
+// blocks until the pool is disconnected. Connections that drop in the meantime
+// reconnect. If the server rejects the nick or pass, the pool is disconnected
+// and Connect returns ErrAuthenticationFailed.
+func (p *Pool) Connect(nick string, pass string) error {
+	p.mu.Lock()
+	if p.running {
+		p.mu.Unlock()
+		return errors.New("Already connected")
+	}
+	p.running = true
+	p.closed = false
+	p.done = make(chan struct{})
+	p.nick, p.pass = nick, pass
+
+	for _, channel := range p.options.Channels {
+		if channel, err := validChannel(channel); err == nil && p.channels[channel] == nil {
+			p.assign(channel)
+		}
+	}
+	for _, pc := range p.conns {
+		p.start(pc)
+	}
+	done := p.done
+	p.mu.Unlock()
+
+	<-done
+	p.wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.running = false
+	err := p.err
+	p.err = nil
+	return err
+}
+
+// Disconnect closes all of the pool's connections. The pool keeps its channels,
+// which are joined again if it reconnects.
+func (p *Pool) Disconnect() {
+	p.stop(nil)
+}
+
+// stop closes all of the pool's connections and makes Connect return err
+func (p *Pool) stop(err error) {
+	p.mu.Lock()
+	if !p.running || p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	p.err = err
+	close(p.done)
+	conns := append([]*poolConn(nil), p.conns...)
+	p.mu.Unlock()
+
+	for _, pc := range conns {
+		pc.client.Disconnect()
+	}
+}
+
+// Join assigns a channel to a connection with room to spare, or to a new
+// connection, and joins it. If the "#" prefix is missing, it is automatically
+// prepended.
+func (p *Pool) Join(channel string) error {
+	channel, err := validChannel(channel)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.channels[channel] == nil {
+		p.assign(channel)
+	}
+	return nil
+}
+
+// Part leaves a channel. If the "#" prefix is missing, it is automatically
+// prepended. A connection left without channels is closed and released.
+func (p *Pool) Part(channel string) error {
+	channel, err := validChannel(channel)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	pc := p.channels[channel]
+	if pc == nil {
+		p.mu.Unlock()
+		return nil
+	}
+	delete(p.channels, channel)
+	delete(pc.channels, channel)
+	if len(pc.channels) == 0 {
+		p.removeConn(pc)
+		p.mu.Unlock()
+		pc.client.Disconnect()
+		return nil
+	}
+	p.mu.Unlock()
+
+	if pc.client.Connected() {
+		return pc.client.Part(channel)
+	}
+	return nil
+}
+
+// Say sends a message to a channel through the connection that joined it, or
+// through any connected connection if no connection joined the channel. If the
+// "#" prefix is missing, it is automatically prepended.
+func (p *Pool) Say(channel string, msg string) error {
+	channel, err := validChannel(channel)
+	if err != nil {
+		return err
+	}
+
+	client := p.client(channel)
+	if client == nil {
+		return ErrNotConnected
+	}
+	return client.Say(channel, msg)
+}
+
+// Channels returns the channels of the pool, sorted alphabetically
+func (p *Pool) Channels() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	channels := make([]string, 0, len(p.channels))
+	for channel := range p.channels {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// Clients returns the Clients of the pool's connections
+func (p *Pool) Clients() []*Client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	clients := make([]*Client, len(p.conns))
+	for i, pc := range p.conns {
+		clients[i] = pc.client
+	}
+	return clients
+}
+
+// Configure adds a function that is called with the Client of each of the pool's
+// connections, including connections made later, e.g., to add event callbacks
+// to all of them
+func (p *Pool) Configure(f func(client *Client)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.configure = append(p.configure, f)
+	for _, pc := range p.conns {
+		f(pc.client)
+	}
+}
+
+// OnAction adds an event callback to every connection for action (e.g., /me) messages
+func (p *Pool) OnAction(callback func(channel string, tags map[string]string, msg string)) {
+	p.Configure(func(client *Client) { client.OnAction(callback) })
+}
+
+// OnChat adds an event callback to every connection for when a user sends a
+// message in a channel
+func (p *Pool) OnChat(callback func(channel string, tags map[string]string, msg string)) {
+	p.Configure(func(client *Client) { client.OnChat(callback) })
+}
+
+// OnCheer adds an event callback to every connection for when a user cheers bits
+// in a channel
+func (p *Pool) OnCheer(callback func(channel string, tags map[string]string, msg string)) {
+	p.Configure(func(client *Client) { client.OnCheer(callback) })
+}
+
+// OnJoin adds an event callback to every connection for when a user joins a channel
+func (p *Pool) OnJoin(callback func(channel, username string)) {
+	p.Configure(func(client *Client) { client.OnJoin(callback) })
+}
+
+// OnPart adds an event callback to every connection for when a user parts a channel
+func (p *Pool) OnPart(callback func(channel, username string)) {
+	p.Configure(func(client *Client) { client.OnPart(callback) })
+}
+
+// OnNotice adds an event callback to every connection for when the server sends
+// a NOTICE
+func (p *Pool) OnNotice(callback func(channel string, msgID NoticeID, text string)) {
+	p.Configure(func(client *Client) { client.OnNotice(callback) })
+}
+
+// OnRoomState adds an event callback to every connection for when a channel's
+// chat settings are first reported, e.g., after joining it, or change
+func (p *Pool) OnRoomState(callback func(channel string, state RoomState, changed map[string]string)) {
+	p.Configure(func(client *Client) { client.OnRoomState(callback) })
+}
+
+// OnUserNotice adds an event callback to every connection for USERNOTICE
+// messages whose msg-id is not handled by any other callback
+func (p *Pool) OnUserNotice(callback func(channel, msgID string, tags map[string]string, msg string)) {
+	p.Configure(func(client *Client) { client.OnUserNotice(callback) })
+}
+
+// client returns the Client of the connection that joined channel, or of any
+// connected connection
+func (p *Pool) client(channel string) *Client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if pc := p.channels[channel]; pc != nil && pc.client.Connected() {
+		return pc.client
+	}
+	for _, pc := range p.conns {
+		if pc.client.Connected() {
+			return pc.client
+		}
+	}
+	return nil
+}
+
+// assign assigns a channel to the first connection with room to spare, or to a
+// new connection. It must be called with p.mu held.
+func (p *Pool) assign(channel string) {
+	var pc *poolConn
+	for _, conn := range p.conns {
+		if len(conn.channels) < p.capacity {
+			pc = conn
+			break
+		}
+	}
+	if pc == nil {
+		pc = p.newConn()
+		if p.running && !p.closed {
+			p.start(pc)
+		}
+	}
+
+	pc.channels[channel] = struct{}{}
+	p.channels[channel] = pc
+	if pc.client.Connected() {
+		pc.client.Join(channel)
+	}
+}
+
+// newConn adds a connection to the pool. It must be called with p.mu held.
+func (p *Pool) newConn() *poolConn {
+	o := p.options
+	o.Channels = nil
+	pc := &poolConn{
+		client:   NewClient(o),
+		channels: make(map[string]struct{}),
+	}
+
+	pc.client.OnStateChange(func(state State) {
+		switch state {
+		case StateConnecting:
+			// Disconnect may have been called, or the connection released, before
+			// the client started connecting
+			p.mu.Lock()
+			closed := p.closed || pc.removed
+			p.mu.Unlock()
+			if closed {
+				pc.client.Disconnect()
+			}
+		case StateConnected:
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			pc.connected = true
+			for channel := range pc.channels {
+				pc.client.Join(channel)
+			}
+		}
+	})
+	for _, f := range p.configure {
+		f(pc.client)
+	}
+
+	p.conns = append(p.conns, pc)
+	return pc
+}
+
+// removeConn releases a connection of the pool. It must be called with p.mu
+// held.
+func (p *Pool) removeConn(pc *poolConn) {
+	pc.removed = true
+	for i, conn := range p.conns {
+		if conn == pc {
+			p.conns = append(p.conns[:i], p.conns[i+1:]...)
+			return
+		}
+	}
+}
+
+// start connects a connection. It must be called with p.mu held.
+func (p *Pool) start(pc *poolConn) {
+	if pc.started {
+		return
+	}
+	pc.started = true
+	p.wg.Add(1)
+	go p.run(pc, p.nick, p.pass, p.done)
+}
+
+// run connects a connection and reconnects it whenever it drops, until the pool
+// is disconnected
+func (p *Pool) run(pc *poolConn, nick, pass string, done chan struct{}) {
+	defer p.wg.Done()
+
+	var delay time.Duration
+	for {
+		if delay > 0 {
+			select {
+			case <-done:
+				p.mu.Lock()
+				pc.started = false
+				p.mu.Unlock()
+				return
+			case <-time.After(delay):
+			}
+		}
+
+		err := pc.client.Connect(nick, pass)
+		if err == ErrAuthenticationFailed {
+			// Every connection uses the same credentials, so the others fail too
+			p.stop(err)
+		}
+
+		var ok bool
+		if delay, ok = p.dropped(pc); !ok {
+			return
+		}
+	}
+}
+
+// dropped moves the channels of a connection that was closed to the connected
+// connections with room to spare, and returns how long the connection waits
+// before it reconnects to join the channels that didn't fit. It returns false
+// if the connection doesn't reconnect because the pool was disconnected or the
+// connection has no channels left, in which case it is released. The delay
+// starts at reconnectDelay and doubles with every attempt in a row that failed
+// to connect.
+func (p *Pool) dropped(pc *poolConn) (time.Duration, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || pc.removed {
+		pc.started = false
+		return 0, false
+	}
+
+	channels := make([]string, 0, len(pc.channels))
+	for channel := range pc.channels {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	for _, channel := range channels {
+		conn := p.connWithRoom(pc)
+		if conn == nil {
+			break
+		}
+		delete(pc.channels, channel)
+		conn.channels[channel] = struct{}{}
+		p.channels[channel] = conn
+		conn.client.Join(channel)
+	}
+	if len(pc.channels) == 0 {
+		pc.started = false
+		p.removeConn(pc)
+		return 0, false
+	}
+
+	if pc.connected || pc.backoff == 0 {
+		pc.backoff = p.reconnectDelay
+	} else if pc.backoff *= 2; pc.backoff > maxReconnectDelay {
+		pc.backoff = maxReconnectDelay
+	}
+	pc.connected = false
+	return pc.backoff, true
+}
+
+// connWithRoom returns a connected connection other than except with room to
+// spare, or nil if there is none. It must be called with p.mu held.
+func (p *Pool) connWithRoom(except *poolConn) *poolConn {
+	for _, conn := range p.conns {
+		if conn != except && len(conn.channels) < p.capacity && conn.client.Connected() {
+			return conn
+		}
+	}
+	return nil
+}
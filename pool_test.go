@@ -0,0 +1,249 @@
+package gotirc
+
+import (
+	"bufio"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type serverLine struct {
+	conn net.Conn
+	line string
+}
+
+func TestPool(t *testing.T) {
+	lines := make(chan serverLine, 100)
+	port := startTestServer(t, func(conn net.Conn, line string) {
+		if !strings.HasPrefix(line, "CAP ") {
+			lines <- serverLine{conn, line}
+		}
+	})
+
+	pool := NewPool(Options{Host: "127.0.0.1", Port: port}, 2)
+	pool.reconnectDelay = 0
+	chats := make(chan string, 1)
+	pool.OnChat(func(channel string, tags map[string]string, msg string) {
+		chats <- channel + " " + msg
+	})
+
+	for _, channel := range []string{"a", "b", "c", "d", "e"} {
+		pool.Join(channel)
+	}
+	done := make(chan error)
+	go func() {
+		done <- pool.Connect(username, password)
+	}()
+
+	// awaitJoins returns the connection that joined each channel
+	awaitJoins := func(n int) map[string]net.Conn {
+		joined := make(map[string]net.Conn)
+		for len(joined) < n {
+			l := <-lines
+			for _, channel := range joinChannels(l.line) {
+				joined[channel] = l.conn
+			}
+		}
+		return joined
+	}
+
+	// The channels are spread over three connections
+	joined := awaitJoins(5)
+	perConn := make(map[net.Conn][]string)
+	for channel, conn := range joined {
+		perConn[conn] = append(perConn[conn], channel)
+	}
+	if len(perConn) != 3 {
+		t.Errorf("Expected '3', got '%d'", len(perConn))
+	}
+	for _, channels := range perConn {
+		if len(channels) > 2 {
+			t.Errorf("Expected at most 2 channels, got '%v'", channels)
+		}
+	}
+
+	// Callbacks are added to every connection
+	joined["#c"].Write([]byte(":user!user@user.tmi.twitch.tv PRIVMSG #c :hello\r\n"))
+	if chat := <-chats; chat != "#c hello" {
+		t.Errorf("Expected '#c hello', got '%s'", chat)
+	}
+
+	// Messages are sent through the connection that joined the channel
+	pool.Say("e", "test")
+	if l := <-lines; l.line != "PRIVMSG #e :test" || l.conn != joined["#e"] {
+		t.Errorf("Expected 'PRIVMSG #e :test' on the connection of #e, got '%s'", l.line)
+	}
+
+	// The channels of a dropped connection are moved to the connection with room
+	// to spare, and the connection reconnects to join the channel that didn't fit
+	joined["#a"].Close()
+	rejoined := awaitJoins(2)
+	var channels []string
+	for channel := range rejoined {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	if strings.Join(channels, ",") != "#a,#b" {
+		t.Errorf("Expected '#a,#b', got '%v'", channels)
+	}
+	if rejoined["#a"] != joined["#e"] {
+		t.Errorf("Expected '#a' to move to the connection of '#e'")
+	}
+	if rejoined["#b"] == joined["#b"] || rejoined["#b"] == joined["#e"] {
+		t.Errorf("Expected '#b' to be joined on a new connection")
+	}
+	if n := len(pool.Clients()); n != 3 {
+		t.Errorf("Expected '3', got '%d'", n)
+	}
+	if c := strings.Join(pool.Channels(), ","); c != "#a,#b,#c,#d,#e" {
+		t.Errorf("Expected '#a,#b,#c,#d,#e', got '%s'", c)
+	}
+
+	pool.Disconnect()
+	if err := <-done; err != nil {
+		t.Errorf("Expected 'nil', got '%s'", err)
+	}
+	for _, client := range pool.Clients() {
+		if client.Connected() {
+			t.Error("Expected 'false', got 'true'")
+		}
+	}
+}
+
+func TestPoolRebalance(t *testing.T) {
+	lines := make(chan serverLine, 100)
+	port := startTestServer(t, func(conn net.Conn, line string) {
+		if !strings.HasPrefix(line, "CAP ") {
+			lines <- serverLine{conn, line}
+		}
+	})
+
+	pool := NewPool(Options{Host: "127.0.0.1", Port: port}, 2)
+	pool.reconnectDelay = time.Minute
+	for _, channel := range []string{"a", "b", "c"} {
+		pool.Join(channel)
+	}
+	done := make(chan error)
+	go func() {
+		done <- pool.Connect(username, password)
+	}()
+
+	joined := make(map[string]net.Conn)
+	for len(joined) < 3 {
+		l := <-lines
+		for _, channel := range joinChannels(l.line) {
+			joined[channel] = l.conn
+		}
+	}
+
+	// Parting makes room on the connection of #a
+	pool.Part("b")
+	if l := <-lines; l.line != "PART #b" || l.conn != joined["#a"] {
+		t.Errorf("Expected 'PART #b' on the connection of #a, got '%s'", l.line)
+	}
+
+	// The channel of a dropped connection is joined on the connection with room,
+	// and the empty connection is released instead of reconnecting
+	joined["#c"].Close()
+	if l := <-lines; l.line != "JOIN #c" || l.conn != joined["#a"] {
+		t.Errorf("Expected 'JOIN #c' on the connection of #a, got '%s'", l.line)
+	}
+	for i := 0; len(pool.Clients()) != 1 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := len(pool.Clients()); n != 1 {
+		t.Errorf("Expected '1', got '%d'", n)
+	}
+
+	// A connection left without channels is closed and released
+	client := pool.Clients()[0]
+	pool.Part("a")
+	pool.Part("c")
+	if n := len(pool.Clients()); n != 0 {
+		t.Errorf("Expected '0', got '%d'", n)
+	}
+	if client.Connected() {
+		t.Error("Expected 'false', got 'true'")
+	}
+
+	pool.Disconnect()
+	if err := <-done; err != nil {
+		t.Errorf("Expected 'nil', got '%s'", err)
+	}
+}
+
+func TestPoolAuthenticationFailed(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				in := bufio.NewReader(conn)
+				in.ReadString('\n') // PASS
+				in.ReadString('\n') // NICK
+				conn.Write([]byte(":tmi.twitch.tv NOTICE * :Login authentication failed\r\n"))
+			}()
+		}
+	}()
+
+	pool := NewPool(Options{Host: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port}, 1)
+	pool.reconnectDelay = 0
+	pool.Join("a")
+	pool.Join("b")
+
+	// Connect returns instead of reconnecting forever
+	done := make(chan error)
+	go func() {
+		done <- pool.Connect(username, "oauth:invalid")
+	}()
+	select {
+	case err := <-done:
+		if err != ErrAuthenticationFailed {
+			t.Errorf("Expected '%s', got '%v'", ErrAuthenticationFailed, err)
+		}
+	case <-time.After(5 * time.Second):
+		pool.Disconnect()
+		<-done
+		t.Fatal("Expected Connect to return")
+	}
+	if n := len(pool.Clients()); n != 2 {
+		t.Errorf("Expected '2', got '%d'", n)
+	}
+}
+
+func TestPoolBackoff(t *testing.T) {
+	pool := NewPool(Options{}, 1)
+	pool.Join("a")
+	pc := pool.conns[0]
+
+	// The delay doubles with every failed attempt, up to maxReconnectDelay
+	expected := []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second,
+		80 * time.Second, 160 * time.Second, maxReconnectDelay, maxReconnectDelay}
+	for _, e := range expected {
+		if delay, ok := pool.dropped(pc); !ok || delay != e {
+			t.Errorf("Expected '%s', got '%s'", e, delay)
+		}
+	}
+
+	// and starts over once the connection connected
+	pc.connected = true
+	if delay, _ := pool.dropped(pc); delay != 5*time.Second {
+		t.Errorf("Expected '5s', got '%s'", delay)
+	}
+
+	// Disconnected pools don't reconnect
+	pool.closed = true
+	if _, ok := pool.dropped(pc); ok {
+		t.Error("Expected 'false', got 'true'")
+	}
+}
@@ -0,0 +1,124 @@
+package gotirc
+
+import (
+	"strings"
+	"time"
+)
+
+// priority is the class of a queued message. Messages of a higher class (lower
+// value) are sent before any queued messages of lower classes.
+type priority int
+
+const (
+	// priorityControl is protocol control, such as PONG and CAP, which must not
+	// wait behind chat or the server may drop the connection
+	priorityControl priority = iota
+	// priorityModeration is moderation commands, such as /ban and /timeout
+	priorityModeration
+	// priorityChat is everything else
+	priorityChat
+
+	numPriorities
+)
+
+// moderationCommands are the chat commands sent with priorityModeration
+var moderationCommands = map[string]bool{
+	"ban":            true,
+	"unban":          true,
+	"timeout":        true,
+	"untimeout":      true,
+	"delete":         true,
+	"clear":          true,
+	"slow":           true,
+	"slowoff":        true,
+	"followers":      true,
+	"followersoff":   true,
+	"subscribers":    true,
+	"subscribersoff": true,
+	"emoteonly":      true,
+	"emoteonlyoff":   true,
+	"r9kbeta":        true,
+	"r9kbetaoff":     true,
+	"uniquechat":     true,
+	"uniquechatoff":  true,
+	"mod":            true,
+	"unmod":          true,
+	"vip":            true,
+	"unvip":          true,
+}
+
+// messagePriority returns the class of a message to be sent to the server
+func messagePriority(data string) priority {
+	msg := NewMessage(data)
+	switch msg.Command {
+	case "PONG", "CAP", "JOIN", "PART", "QUIT":
+		return priorityControl
+	case "PRIVMSG":
+		if len(msg.Params) < 2 {
+			return priorityChat
+		}
+		text := msg.Params[1]
+		if !strings.HasPrefix(text, "/") && !strings.HasPrefix(text, ".") {
+			return priorityChat
+		}
+		command := strings.ToLower(strings.SplitN(text[1:], " ", 2)[0])
+		if moderationCommands[command] {
+			return priorityModeration
+		}
+	}
+	return priorityChat
+}
+
+// priorityQueues holds queued messages in a channelQueues per priority
+type priorityQueues [numPriorities]*channelQueues
+
+func newPriorityQueues() *priorityQueues {
+	var q priorityQueues
+	for i := range q {
+		q[i] = newChannelQueues()
+	}
+	return &q
+}
+
+func (q *priorityQueues) push(p priority, channel, msg string) {
+	q[p].push(channel, msg)
+}
+
+// len returns the number of queued messages of all priorities
+func (q *priorityQueues) len() int {
+	n := 0
+	for _, queues := range q {
+		n += queues.size
+	}
+	return n
+}
+
+// pop removes and returns the next message that may be sent at now from the
+// highest priority with one, skipping the channels for which delay, if not nil,
+// returns a positive wait. If no message may be sent, ok is false and wait is
+// the time until one may, or 0 if no messages are queued.
+func (q *priorityQueues) pop(now time.Time, delay func(p priority, channel string) time.Duration) (p priority, channel, msg string, ok bool, wait time.Duration) {
+	for i := range q {
+		var channelDelay func(channel string) time.Duration
+		if delay != nil {
+			p := priority(i)
+			channelDelay = func(channel string) time.Duration { return delay(p, channel) }
+		}
+
+		var w time.Duration
+		channel, msg, ok, w = q[i].pop(now, channelDelay)
+		if ok {
+			return priority(i), channel, msg, true, 0
+		}
+		if w > 0 && (wait == 0 || w < wait) {
+			wait = w
+		}
+	}
+	return 0, "", "", false, wait
+}
+
+// sent records that a message of priority p was sent to a channel. See
+// channelQueues.sent.
+func (q *priorityQueues) sent(p priority, channel string, at time.Time, interval time.Duration) {
+	q[p].sent(channel, at, interval)
+}
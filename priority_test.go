@@ -0,0 +1,143 @@
+package gotirc
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMessagePriority(t *testing.T) {
+	tests := map[string]priority{
+		"PONG :tmi.twitch.tv":                   priorityControl,
+		"CAP REQ :twitch.tv/tags":               priorityControl,
+		"PART #test":                            priorityControl,
+		"PRIVMSG #test :/timeout spammer 600":   priorityModeration,
+		"PRIVMSG #test :.BAN spammer":           priorityModeration,
+		"PRIVMSG #test :/me waves":              priorityChat,
+		"PRIVMSG #test :hello":                  priorityChat,
+		"@client-nonce=abc PRIVMSG #test :/ban": priorityModeration,
+	}
+	for data, expected := range tests {
+		if p := messagePriority(data); p != expected {
+			t.Errorf("Expected '%d' for '%s', got '%d'", expected, data, p)
+		}
+	}
+}
+
+func TestPriorityQueues(t *testing.T) {
+	q := newPriorityQueues()
+	now := time.Now()
+
+	q.push(priorityChat, "#test", "chat")
+	q.push(priorityModeration, "#test", "mod")
+	q.push(priorityControl, "", "PONG")
+
+	expected := []string{"PONG", "mod", "chat"}
+	for _, e := range expected {
+		_, _, msg, ok, _ := q.pop(now, nil)
+		if !ok || msg != e {
+			t.Errorf("Expected '%s', got '%s'", e, msg)
+		}
+	}
+
+	// Lower priorities are sent while a higher one waits for its interval
+	q.push(priorityModeration, "#test", "mod")
+	q.push(priorityChat, "#test", "chat")
+	p, channel, _, _, _ := q.pop(now, nil)
+	q.sent(p, channel, now, 30*time.Second)
+	if _, _, msg, ok, _ := q.pop(now, nil); !ok || msg != "chat" {
+		t.Errorf("Expected 'chat', got '%s'", msg)
+	}
+}
+
+func TestControlPriority(t *testing.T) {
+	var wg sync.WaitGroup
+	client, server := createClientServer()
+	clock := newFakeClock()
+	client.options.Clock = clock
+	client.sendQueue = make(chan string, sendBufferSize)
+	client.state = StateConnected
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		client.startSendLoop(1, 2)
+	}()
+
+	lines := readLines(server)
+	for i := 0; i < 2; i++ {
+		client.Say("test", "test")
+	}
+	line := <-lines
+	if line != "PRIVMSG #test :test\r\n" {
+		t.Errorf("Expected 'PRIVMSG #test :test', got '%s'", line)
+	}
+
+	// The chat limit is used up, but control messages have their own
+	start := clock.Now()
+	client.doCallbacks("PING :tmi.twitch.tv")
+	line = <-lines
+	if strings.TrimSpace(line) != "PONG :tmi.twitch.tv" {
+		t.Errorf("Expected 'PONG :tmi.twitch.tv', got '%s'", line)
+	}
+	if delta := clock.Now().Sub(start); delta != 0 {
+		t.Errorf("Expected delta 0s, got %s", delta)
+	}
+
+	server.Close()
+	client.Say("test", "X")
+	clock.wait(&wg)
+}
+
+func TestControlDuringRateLimitWait(t *testing.T) {
+	var wg sync.WaitGroup
+	client, server := createClientServer()
+	defer server.Close()
+	clock := newFakeClock()
+	client.options.Clock = clock
+	client.sendQueue = make(chan string, sendBufferSize)
+	client.state = StateConnected
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		client.startSendLoop(1, 2)
+	}()
+
+	lines := readLines(server)
+	client.Say("a", "test")
+	client.Say("b", "test")
+	if line := <-lines; line != "PRIVMSG #a :test\r\n" {
+		t.Errorf("Expected 'PRIVMSG #a :test', got '%s'", line)
+	}
+
+	// The chat message to #b waits for the chat limit, and a PONG queued in the
+	// meantime is sent without waiting for it
+	<-clock.pending
+	client.doCallbacks("PING :tmi.twitch.tv")
+	select {
+	case line := <-lines:
+		if strings.TrimSpace(line) != "PONG :tmi.twitch.tv" {
+			t.Errorf("Expected 'PONG :tmi.twitch.tv', got '%s'", line)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected PONG during the wait")
+	}
+
+	// Disconnecting doesn't wait for the chat limit either
+	client.Disconnect()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Expected the send loop to return")
+		server.Close()
+		clock.Advance(time.Minute)
+		clock.wait(&wg)
+	}
+}
@@ -0,0 +1,214 @@
+package gotirc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimit describes how many messages may be sent to the server in a period
+// of time
+type RateLimit struct {
+	Messages int
+	Per      time.Duration
+}
+
+// Rate limit presets for the kinds of accounts Twitch distinguishes. Each allows
+// one message fewer than Twitch's documented limit to leave room for differences
+// in timing between the client and the server.
+var (
+	// RateLimitUser applies to regular accounts in channels where they are not a
+	// moderator or the broadcaster
+	RateLimitUser = RateLimit{Messages: 19, Per: 30 * time.Second}
+	// RateLimitModerator applies to channels where the account is a moderator or
+	// the broadcaster
+	RateLimitModerator = RateLimit{Messages: 99, Per: 30 * time.Second}
+	// RateLimitKnownBot applies to accounts registered as known bots
+	RateLimitKnownBot = RateLimit{Messages: 49, Per: 30 * time.Second}
+	// RateLimitVerifiedBot applies to accounts registered as verified bots
+	RateLimitVerifiedBot = RateLimit{Messages: 7499, Per: 30 * time.Second}
+
+	// JoinRateLimitUser limits the channels joined by regular accounts
+	JoinRateLimitUser = RateLimit{Messages: 20, Per: 10 * time.Second}
+	// JoinRateLimitVerifiedBot limits the channels joined by verified bots
+	JoinRateLimitVerifiedBot = RateLimit{Messages: 2000, Per: 10 * time.Second}
+)
+
+// Clock tells the time and waits for time to pass. A RateLimiter (and a Client,
+// through Options.Clock) uses it instead of the time package, so that tests can
+// control the passage of time.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SystemClock is the Clock backed by the time package
+var SystemClock Clock = systemClock{}
+
+// RateLimiter is a token bucket that allows bursts of up to limit.Messages
+// messages and refills at a constant rate of limit.Messages per limit.Per. It is
+// safe for concurrent use.
+type RateLimiter struct {
+	mu       sync.Mutex
+	clock    Clock
+	capacity float64
+	rate     float64 // Tokens per second
+	tokens   float64
+	lastTick time.Time
+}
+
+// NewRateLimiter returns a RateLimiter for limit with a full bucket. If clock is
+// nil, SystemClock is used.
+func NewRateLimiter(limit RateLimit, clock Clock) *RateLimiter {
+	return newRateLimiter(float64(limit.Messages), limit.Per.Seconds(), clock)
+}
+
+func newRateLimiter(maxMessages, perSeconds float64, clock Clock) *RateLimiter {
+	if clock == nil {
+		clock = SystemClock
+	}
+	return &RateLimiter{
+		clock:    clock,
+		capacity: maxMessages,
+		rate:     maxMessages / perSeconds,
+		tokens:   maxMessages,
+		lastTick: clock.Now(),
+	}
+}
+
+// refill must be called with l.mu held
+func (l *RateLimiter) refill() {
+	now := l.clock.Now()
+	l.tokens += now.Sub(l.lastTick).Seconds() * l.rate
+	l.lastTick = now
+	if l.tokens > l.capacity {
+		l.tokens = l.capacity
+	}
+}
+
+// Delay returns how long to wait until a message may be sent
+func (l *RateLimiter) Delay() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	if l.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// Available returns the number of messages that may be sent now
+func (l *RateLimiter) Available() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	return int(l.tokens)
+}
+
+// Take records that a message was sent, whether or not the limit allowed it
+func (l *RateLimiter) Take() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	l.tokens--
+}
+
+// Allow records that a message is sent and returns true if the limit allows
+// sending a message now, or returns false otherwise
+func (l *RateLimiter) Allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// Wait blocks until the limit allows sending a message and records that it is
+// sent, or returns ctx.Err() if ctx is done first
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		if l.Allow() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-l.clock.After(l.Delay()):
+		}
+	}
+}
+
+// rateLimits returns the limits used for channels where the client is a regular
+// user and where it is a moderator
+func (c *Client) rateLimits() (user, mod RateLimit) {
+	user, mod = c.options.RateLimit, c.options.ModeratorRateLimit
+	if user.Messages <= 0 || user.Per <= 0 {
+		user = RateLimitUser
+	}
+	if mod.Messages <= 0 || mod.Per <= 0 {
+		mod = RateLimitModerator
+	}
+	return user, mod
+}
+
+// joinRateLimit returns the limit for the number of channels joined
+func (c *Client) joinRateLimit() RateLimit {
+	limit := c.options.JoinRateLimit
+	if limit.Messages <= 0 || limit.Per <= 0 {
+		limit = JoinRateLimitUser
+	}
+	return limit
+}
+
+// clock returns the Clock used by the rate limiters
+func (c *Client) clock() Clock {
+	if c.options.Clock == nil {
+		return SystemClock
+	}
+	return c.options.Clock
+}
+
+// afterFunc runs f in its own goroutine once d has passed on the client's clock,
+// unless the returned function is called first
+func (c *Client) afterFunc(d time.Duration, f func()) (stop func()) {
+	after := c.clock().After(d)
+	stopped := make(chan struct{})
+	var once sync.Once
+	go func() {
+		select {
+		case <-after:
+			f()
+		case <-stopped:
+		}
+	}()
+	return func() {
+		once.Do(func() { close(stopped) })
+	}
+}
+
+// isSelfModerator returns true if USERSTATE reported that the client is a
+// moderator or the broadcaster of the channel
+func (c *Client) isSelfModerator(channel string) bool {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	state := c.userStates[channel]
+	return state.Mod || state.Broadcaster
+}
+
+// messageChannel returns the channel a PRIVMSG is sent to, or an empty string
+// for other messages
+func messageChannel(data string) string {
+	msg := NewMessage(data)
+	if msg.Command != "PRIVMSG" || len(msg.Params) < 1 {
+		return ""
+	}
+	return msg.Params[0]
+}
@@ -0,0 +1,275 @@
+package gotirc
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimits(t *testing.T) {
+	client := NewClient(Options{})
+	user, mod := client.rateLimits()
+	if user != RateLimitUser || mod != RateLimitModerator {
+		t.Errorf("Expected '%v' and '%v', got '%v' and '%v'", RateLimitUser, RateLimitModerator, user, mod)
+	}
+
+	client = NewClient(Options{RateLimit: RateLimitVerifiedBot})
+	if user, _ := client.rateLimits(); user != RateLimitVerifiedBot {
+		t.Errorf("Expected '%v', got '%v'", RateLimitVerifiedBot, user)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewRateLimiter(RateLimit{Messages: 2, Per: 10 * time.Second}, clock)
+
+	if n := limiter.Available(); n != 2 {
+		t.Errorf("Expected '2', got '%d'", n)
+	}
+	if !limiter.Allow() || !limiter.Allow() {
+		t.Errorf("Expected 'true', got 'false'")
+	}
+	if limiter.Allow() {
+		t.Errorf("Expected 'false', got 'true'")
+	}
+	if d := limiter.Delay(); d != 5*time.Second {
+		t.Errorf("Expected '5s', got '%s'", d)
+	}
+
+	clock.Advance(5 * time.Second)
+	if d := limiter.Delay(); d != 0 {
+		t.Errorf("Expected '0s', got '%s'", d)
+	}
+	limiter.Take()
+
+	// Wait blocks until a token is refilled
+	done := make(chan error)
+	go func() {
+		done <- limiter.Wait(context.Background())
+	}()
+	<-clock.pending
+	clock.Advance(5 * time.Second)
+	if err := <-done; err != nil {
+		t.Errorf("Expected 'nil', got '%s'", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.Wait(ctx); err != context.Canceled {
+		t.Errorf("Expected '%s', got '%s'", context.Canceled, err)
+	}
+}
+
+func TestModeratorSendLoop(t *testing.T) {
+	var wg sync.WaitGroup
+	client, server := createClientServer()
+	clock := newFakeClock()
+	client.options.Clock = clock
+	client.sendQueue = make(chan string, sendBufferSize)
+	client.state = StateConnected
+	client.userStates = map[string]UserState{"#modchan": {Mod: true}}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		client.startSendLoop(2, 10)
+	}()
+
+	lines := readLines(server)
+
+	// Channels where the client is a moderator aren't limited by the user limit
+	start := clock.Now()
+	for i := 0; i < 10; i++ {
+		client.Say("modchan", "test")
+	}
+	for i := 0; i < 10; i++ {
+		<-lines
+	}
+	if delta := clock.Now().Sub(start); delta != 0 {
+		t.Errorf("Expected delta 0s, got %s", delta)
+	}
+
+	// Other channels are
+	client.Say("userchan", "test")
+	client.Say("userchan", "test")
+	client.Say("userchan", "test")
+	start = clock.Now()
+	for i := 0; i < 3; i++ {
+		nextLine(clock, lines) // Waiting for the channel interval and the user limit
+	}
+	if delta := clock.Now().Sub(start); delta < 4*time.Second {
+		t.Errorf("Expected delta > 4s, got %s", delta)
+	}
+
+	server.Close()
+	client.Say("userchan", "X")
+	clock.wait(&wg)
+}
+
+func TestModeratorChatSkipsUserLimit(t *testing.T) {
+	var wg sync.WaitGroup
+	client, server := createClientServer()
+	clock := newFakeClock()
+	client.options.Clock = clock
+	client.sendQueue = make(chan string, sendBufferSize)
+	client.state = StateConnected
+	client.userStates = map[string]UserState{"#modchan": {Mod: true}}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		client.startSendLoop(1, 30)
+	}()
+
+	lines := readLines(server)
+	client.Say("otherchan", "1")
+	if line := <-lines; line != "PRIVMSG #otherchan :1\r\n" {
+		t.Errorf("Expected 'PRIVMSG #otherchan :1', got '%s'", line)
+	}
+
+	// The message to #userchan waits for the user limit, while the message to
+	// #modchan queued after it is sent right away
+	start := clock.Now()
+	client.Say("userchan", "2")
+	client.Say("modchan", "3")
+	if line := <-lines; line != "PRIVMSG #modchan :3\r\n" {
+		t.Errorf("Expected 'PRIVMSG #modchan :3', got '%s'", line)
+	}
+	if delta := clock.Now().Sub(start); delta != 0 {
+		t.Errorf("Expected delta 0s, got %s", delta)
+	}
+
+	server.Close()
+	clock.wait(&wg)
+}
+
+// fakeClock is a Clock whose time only passes when advanced
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []fakeTimer
+	pending chan struct{} // Signalled when a timer is created
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:     time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		pending: make(chan struct{}, 1),
+	}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := make(chan time.Time, 1)
+	if d <= 0 {
+		c <- f.now
+		return c
+	}
+	f.timers = append(f.timers, fakeTimer{at: f.now.Add(d), c: c})
+	f.signal()
+	return c
+}
+
+// signal must be called with f.mu held
+func (f *fakeClock) signal() {
+	select {
+	case f.pending <- struct{}{}:
+	default:
+	}
+}
+
+// Advance moves the time forward by d, firing the timers that expire
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.advance(f.now.Add(d))
+}
+
+// advanceToNext moves the time forward to the earliest timer, firing it
+func (f *fakeClock) advanceToNext() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.timers) == 0 {
+		return
+	}
+	next := f.timers[0].at
+	for _, timer := range f.timers {
+		if timer.at.Before(next) {
+			next = timer.at
+		}
+	}
+	f.advance(next)
+}
+
+// advance must be called with f.mu held
+func (f *fakeClock) advance(to time.Time) {
+	f.now = to
+	timers := f.timers[:0]
+	for _, timer := range f.timers {
+		if timer.at.After(f.now) {
+			timers = append(timers, timer)
+		} else {
+			timer.c <- f.now
+		}
+	}
+	f.timers = timers
+	if len(f.timers) > 0 {
+		f.signal()
+	}
+}
+
+// readLines reads lines from conn into the returned channel until conn is closed
+func readLines(conn net.Conn) <-chan string {
+	lines := make(chan string)
+	go func() {
+		in := bufio.NewReader(conn)
+		for {
+			line, err := in.ReadString('\n')
+			if err != nil {
+				return
+			}
+			lines <- line
+		}
+	}()
+	return lines
+}
+
+// step waits until the send loop is waiting for time to pass, and advances the
+// clock to the end of the wait
+func (f *fakeClock) step() {
+	<-f.pending
+	f.advanceToNext()
+}
+
+// wait waits for wg, advancing the clock whenever the send loop is waiting for
+// time to pass
+func (f *fakeClock) wait(wg *sync.WaitGroup) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		case <-f.pending:
+			f.advanceToNext()
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package gotirc
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// anonymousPass is the pass sent with anonymous (justinfan) nicks, which the
+// server accepts without authentication
+const anonymousPass = "SCHMOOPIIE"
+
+// anonymousNick returns a random anonymous nick, which can read chat but not
+// send messages
+func anonymousNick() string {
+	n, _ := rand.Int(rand.Reader, big.NewInt(900000))
+	return fmt.Sprintf("justinfan%d", n.Int64()+100000)
+}
+
+// newReader returns the Client of the anonymous read connection used when
+// Options.SeparateReader is set
+func (c *Client) newReader() *Client {
+	o := c.options
+	o.Channels = nil
+	o.SeparateReader = false
+	r := NewClient(o)
+	r.parent = c
+
+	r.OnStateChange(func(state State) {
+		switch state {
+		case StateConnecting:
+			// The reader may have been stopped before it started connecting
+			c.readerMu.Lock()
+			stopped := c.readerStopped
+			for channel := range c.readerChannels {
+				c.readerChannels[channel] = false
+			}
+			c.readerMu.Unlock()
+			c.resetJoined()
+			if stopped {
+				r.Disconnect()
+			}
+		}
+	})
+	return r
+}
+
+// ReaderState returns the state of the read connection when
+// Options.SeparateReader is set, or StateDisconnected otherwise
+func (c *Client) ReaderState() State {
+	if c.readClient == nil {
+		return StateDisconnected
+	}
+	return c.readClient.State()
+}
+
+// startReader connects the read connection, reconnecting it whenever it drops,
+// until the returned function is called
+func (c *Client) startReader() (stop func()) {
+	c.readerMu.Lock()
+	c.readerChannels = make(map[string]bool)
+	c.readerStopped = false
+	c.readerMu.Unlock()
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for {
+			err := c.readClient.Connect(anonymousNick(), anonymousPass)
+			c.log("Read connection closed: %v", err)
+			select {
+			case <-done:
+				return
+			case <-time.After(c.readerReconnectDelay):
+			}
+		}
+	}()
+
+	return func() {
+		c.readerMu.Lock()
+		c.readerStopped = true
+		c.readerMu.Unlock()
+		close(done)
+		c.readClient.Disconnect()
+		<-finished
+	}
+}
+
+// joinReader records that the read connection should be in the channels, and
+// joins those it hasn't joined yet if the read connection is connected. They are
+// queued as a single JOIN, which the send loop splits into batches, so that any
+// number of channels fit in the send queue.
+func (c *Client) joinReader(channels ...string) error {
+	if !c.Connected() {
+		c.doDroppedCallbacks("JOIN "+strings.Join(channels, ","), ErrNotConnected)
+		return ErrNotConnected
+	}
+
+	c.readerMu.Lock()
+	defer c.readerMu.Unlock()
+	var joins []string
+	for _, channel := range channels {
+		if !c.readerChannels[channel] {
+			c.readerChannels[channel] = false
+			joins = append(joins, channel)
+		}
+	}
+	if len(joins) == 0 || !c.readClient.Connected() {
+		return nil
+	}
+	if err := c.readClient.send("JOIN %s", strings.Join(joins, ",")); err != nil {
+		return err
+	}
+	for _, channel := range joins {
+		c.readerChannels[channel] = true
+	}
+	return nil
+}
+
+// readerJoins returns the channels the read connection should be in but hasn't
+// joined, and records them as joined. The read connection hands them straight to
+// its send loop when it connects, since queuing a JOIN for each of them could
+// overflow its send queue.
+func (c *Client) readerJoins() []string {
+	c.readerMu.Lock()
+	defer c.readerMu.Unlock()
+	var channels []string
+	for _, channel := range c.readerChannelList() {
+		if !c.readerChannels[channel] {
+			c.readerChannels[channel] = true
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+// readerParted records that the read connection is no longer in a channel it
+// should be in, e.g., because the server parted it or rejected the JOIN, so that
+// joining the channel again sends a JOIN
+func (c *Client) readerParted(channel string) {
+	if c.readClient == nil {
+		return
+	}
+	c.readerMu.Lock()
+	defer c.readerMu.Unlock()
+	if _, ok := c.readerChannels[channel]; ok {
+		c.readerChannels[channel] = false
+	}
+}
+
+// partReader records that the read connection should no longer be in a channel,
+// and parts it if the read connection is connected
+func (c *Client) partReader(channel string) error {
+	if !c.Connected() {
+		c.doDroppedCallbacks("PART "+channel, ErrNotConnected)
+		return ErrNotConnected
+	}
+
+	c.readerMu.Lock()
+	joined := c.readerChannels[channel]
+	delete(c.readerChannels, channel)
+	c.readerMu.Unlock()
+	if joined && c.readClient.Connected() {
+		return c.readClient.Part(channel)
+	}
+	return nil
+}
+
+// readerChannelList returns the channels the read connection should be in. It
+// must be called with c.readerMu held.
+func (c *Client) readerChannelList() []string {
+	channels := make([]string, 0, len(c.readerChannels))
+	for channel := range c.readerChannels {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// isReader returns true if nick is the nick of the read connection
+func (c *Client) isReader(nick string) bool {
+	if c.readClient == nil {
+		return false
+	}
+	c.readClient.stateMu.RLock()
+	defer c.readClient.stateMu.RUnlock()
+	return c.readClient.nick != "" && strings.EqualFold(c.readClient.nick, nick)
+}
+
+// doReaderCallbacks runs the callbacks for a line received by the read
+// connection. USERSTATE and GLOBALUSERSTATE describe the anonymous nick of the
+// read connection rather than the client, so they are ignored.
+func (c *Client) doReaderCallbacks(line string, msg *Message) {
+	if msg.Command == "USERSTATE" || msg.Command == "GLOBALUSERSTATE" {
+		return
+	}
+	c.doCallbacks(line)
+}
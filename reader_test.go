@@ -0,0 +1,196 @@
+package gotirc
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnonymousNick(t *testing.T) {
+	nick := anonymousNick()
+	if !strings.HasPrefix(nick, "justinfan") || len(nick) != len("justinfan")+6 {
+		t.Errorf("Expected 'justinfan' and 6 digits, got '%s'", nick)
+	}
+}
+
+func TestSeparateReader(t *testing.T) {
+	lines := make(chan serverLine, 100)
+	port := startTestServer(t, func(conn net.Conn, line string) {
+		if !strings.HasPrefix(line, "CAP ") {
+			lines <- serverLine{conn, line}
+		}
+	})
+
+	client := NewClient(Options{Host: "127.0.0.1", Port: port, SeparateReader: true})
+	client.readerReconnectDelay = 0
+	connected := make(chan struct{}, 1)
+	client.OnStateChange(func(state State) {
+		if state == StateConnected {
+			connected <- struct{}{}
+		}
+	})
+	chats := make(chan string, 1)
+	client.OnChat(func(channel string, tags map[string]string, msg string) {
+		chats <- channel + " " + msg
+	})
+
+	done := make(chan error)
+	go func() {
+		done <- client.Connect(username, password)
+	}()
+	<-connected
+
+	// JOINs go to the read connection and messages to the other one
+	client.Join("test")
+	join := <-lines
+	if join.line != "JOIN #test" {
+		t.Errorf("Expected 'JOIN #test', got '%s'", join.line)
+	}
+	reader := join.conn
+
+	client.Say("test", "hello")
+	if say := <-lines; say.line != "PRIVMSG #test :hello" || say.conn == reader {
+		t.Errorf("Expected 'PRIVMSG #test :hello' on the write connection, got '%s'", say.line)
+	}
+
+	// The read connection's messages run the client's callbacks, and it answers
+	// its own PINGs
+	reader.Write([]byte(":user!user@user.tmi.twitch.tv PRIVMSG #test :hi\r\n"))
+	if chat := <-chats; chat != "#test hi" {
+		t.Errorf("Expected '#test hi', got '%s'", chat)
+	}
+	reader.Write([]byte("PING :tmi.twitch.tv\r\n"))
+	if pong := <-lines; pong.line != "PONG :tmi.twitch.tv" || pong.conn != reader {
+		t.Errorf("Expected 'PONG :tmi.twitch.tv' on the read connection, got '%s'", pong.line)
+	}
+
+	// The read connection reconnects and rejoins its channels without affecting
+	// the write connection
+	reader.Close()
+	if join := <-lines; join.line != "JOIN #test" || join.conn == reader {
+		t.Errorf("Expected 'JOIN #test' on a new connection, got '%s'", join.line)
+	}
+	if !client.Connected() {
+		t.Error("Expected 'true', got 'false'")
+	}
+
+	client.Disconnect()
+	<-done
+	if state := client.ReaderState(); state != StateDisconnected {
+		t.Errorf("Expected '%s', got '%s'", StateDisconnected, state)
+	}
+}
+
+func TestReaderJoinsManyChannels(t *testing.T) {
+	joins := make(chan serverLine, 1000)
+	port := startTestServer(t, func(conn net.Conn, line string) {
+		if strings.HasPrefix(line, "JOIN ") {
+			joins <- serverLine{conn, line}
+		}
+	})
+
+	// More channels than the send queue holds
+	var channels []string
+	for i := 0; i < 2*sendBufferSize; i++ {
+		channels = append(channels, fmt.Sprintf("#channel%d", i))
+	}
+	client := NewClient(Options{
+		Host:           "127.0.0.1",
+		Port:           port,
+		Channels:       channels,
+		SeparateReader: true,
+		JoinRateLimit:  RateLimit{Messages: 10000, Per: time.Second},
+	})
+	client.readerReconnectDelay = 0
+	done := make(chan error)
+	go func() {
+		done <- client.Connect(username, password)
+	}()
+	defer func() {
+		client.Disconnect()
+		<-done
+	}()
+
+	// awaitJoins returns the connection that joined all of the channels
+	awaitJoins := func() net.Conn {
+		var conn net.Conn
+		seen := make(map[string]bool)
+		timeout := time.After(5 * time.Second)
+		for len(seen) < len(channels) {
+			select {
+			case l := <-joins:
+				conn = l.conn
+				for _, channel := range joinChannels(l.line) {
+					seen[channel] = true
+				}
+			case <-timeout:
+				t.Fatalf("Expected %d channels to be joined, got %d", len(channels), len(seen))
+			}
+		}
+		return conn
+	}
+
+	// Every channel is joined, and joined again when the read connection
+	// reconnects
+	reader := awaitJoins()
+	reader.Close()
+	if conn := awaitJoins(); conn == reader {
+		t.Error("Expected the channels to be joined on a new connection")
+	}
+}
+
+func TestReaderRejoin(t *testing.T) {
+	lines := make(chan serverLine, 100)
+	port := startTestServer(t, func(conn net.Conn, line string) {
+		if strings.HasPrefix(line, "JOIN ") {
+			lines <- serverLine{conn, line}
+		}
+	})
+
+	client := NewClient(Options{Host: "127.0.0.1", Port: port, SeparateReader: true})
+	done := make(chan error)
+	go func() {
+		done <- client.Connect(username, password)
+	}()
+	defer func() {
+		client.Disconnect()
+		<-done
+	}()
+	for !client.Connected() || client.ReaderState() != StateConnected {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	client.Join("test")
+	reader := (<-lines).conn
+	readerNick := client.readClient.nick
+
+	// A channel the server refused or parted is joined again by the next Join
+	rejections := []string{
+		"@msg-id=msg_channel_suspended :tmi.twitch.tv NOTICE #test :This channel has been suspended.\r\n",
+		":" + readerNick + "!" + readerNick + "@" + readerNick + ".tmi.twitch.tv PART #test\r\n",
+	}
+	for _, rejection := range rejections {
+		reader.Write([]byte(rejection))
+		for i := 0; i < 100; i++ {
+			client.readerMu.Lock()
+			joined := client.readerChannels["#test"]
+			client.readerMu.Unlock()
+			if !joined {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		client.Join("test")
+		select {
+		case l := <-lines:
+			if l.line != "JOIN #test" {
+				t.Errorf("Expected 'JOIN #test', got '%s'", l.line)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("Expected 'JOIN #test' after '%s'", strings.TrimSpace(rejection))
+		}
+	}
+}
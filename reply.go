@@ -0,0 +1,45 @@
+package gotirc
+
+import "errors"
+
+// ErrInvalidReplyParent is returned by Reply when the id of the parent message
+// is empty
+var ErrInvalidReplyParent = errors.New("invalid reply parent message id")
+
+// ReplyParent describes the message that a chat message replies to
+type ReplyParent struct {
+	MsgID       string
+	UserID      string
+	UserLogin   string
+	DisplayName string
+	Body        string
+}
+
+// NewReplyParent returns the parent message described by the reply-parent-*
+// tags of a chat message. The second return value is false if the message is
+// not a reply.
+func NewReplyParent(tags map[string]string) (ReplyParent, bool) {
+	id, ok := tags["reply-parent-msg-id"]
+	if !ok || id == "" {
+		return ReplyParent{}, false
+	}
+
+	return ReplyParent{
+		MsgID:       id,
+		UserID:      tags["reply-parent-user-id"],
+		UserLogin:   tags["reply-parent-user-login"],
+		DisplayName: unescapeTagValue(tags["reply-parent-display-name"]),
+		Body:        unescapeTagValue(tags["reply-parent-msg-body"]),
+	}, true
+}
+
+// Reply sends a message to a channel as a reply to the message with the given
+// id (the id tag of the parent message). It returns ErrInvalidReplyParent if
+// parentMsgID is empty.
+func (c *Client) Reply(channel, parentMsgID, msg string) error {
+	if parentMsgID == "" {
+		return ErrInvalidReplyParent
+	}
+	_, err := c.SayWithTags(channel, msg, map[string]string{"reply-parent-msg-id": parentMsgID})
+	return err
+}
@@ -0,0 +1,71 @@
+package gotirc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReply(t *testing.T) {
+	client := NewClient(Options{})
+	client.sendQueue = make(chan string, sendBufferSize)
+	client.state = StateConnected
+
+	client.Reply("channel1", "b34ccfc7-4977-403a-8a94-33c6bac34fb8", "This is a test")
+
+	select {
+	case data := <-client.sendQueue:
+		msg := NewMessage(data)
+		if msg.Tags["reply-parent-msg-id"] != "b34ccfc7-4977-403a-8a94-33c6bac34fb8" {
+			t.Errorf("Expected 'b34ccfc7-4977-403a-8a94-33c6bac34fb8', got '%s'", msg.Tags["reply-parent-msg-id"])
+		}
+		expect := "PRIVMSG #channel1 :This is a test"
+		if !strings.HasSuffix(data, " "+expect) {
+			t.Errorf("Expected '%s', got '%s'", expect, data)
+		}
+	default:
+		t.Error("Expected nonempty channel")
+	}
+
+	if err := client.Reply("channel1", "", "This is a test"); err != ErrInvalidReplyParent {
+		t.Errorf("Expected '%s', got '%v'", ErrInvalidReplyParent, err)
+	}
+	if n := len(client.sendQueue); n != 0 {
+		t.Errorf("Expected '0', got '%d'", n)
+	}
+}
+
+func TestNewReplyParent(t *testing.T) {
+	if _, ok := NewReplyParent(map[string]string{"display-name": "Test_Nick"}); ok {
+		t.Error("Expected 'false', got 'true'")
+	}
+
+	var gotParent ReplyParent
+	var gotOK bool
+	client := NewClient(Options{})
+	client.OnChat(func(channel string, tags map[string]string, msg string) {
+		gotParent, gotOK = NewReplyParent(tags)
+	})
+
+	line := createMessage("PRIVMSG", "#test", []string{"@Parent_Nick hello"}, map[string]string{
+		"reply-parent-display-name": "Parent_Nick",
+		"reply-parent-msg-body":     `Is\sanyone\shere?`,
+		"reply-parent-msg-id":       "b34ccfc7-4977-403a-8a94-33c6bac34fb8",
+		"reply-parent-user-id":      "123",
+		"reply-parent-user-login":   "parent_nick",
+	})
+	client.doCallbacks(line)
+
+	if !gotOK {
+		t.Fatal("Expected 'true', got 'false'")
+	}
+	expected := ReplyParent{
+		MsgID:       "b34ccfc7-4977-403a-8a94-33c6bac34fb8",
+		UserID:      "123",
+		UserLogin:   "parent_nick",
+		DisplayName: "Parent_Nick",
+		Body:        "Is anyone here?",
+	}
+	if gotParent != expected {
+		t.Errorf("Expected '%+v', got '%+v'", expected, gotParent)
+	}
+}
@@ -0,0 +1,120 @@
+package gotirc
+
+import "strconv"
+
+// RoomState holds the chat settings of a channel as reported by ROOMSTATE
+type RoomState struct {
+	RoomID        string
+	EmoteOnly     bool
+	FollowersOnly int // Minimum follow time in minutes; -1 if disabled
+	R9K           bool
+	Slow          int // Seconds a user must wait between messages; 0 if disabled
+	SubsOnly      bool
+}
+
+// roomStateTags lists the ROOMSTATE tags that describe a room setting
+var roomStateTags = []string{"emote-only", "followers-only", "r9k", "slow", "subs-only"}
+
+func newRoomState() RoomState {
+	return RoomState{FollowersOnly: -1}
+}
+
+// update applies the settings present in tags to the room state and returns the
+// settings whose values changed
+func (s *RoomState) update(tags map[string]string) map[string]string {
+	changed := make(map[string]string)
+	if id, ok := tags["room-id"]; ok {
+		s.RoomID = id
+	}
+
+	for _, name := range roomStateTags {
+		value, ok := tags[name]
+		if !ok {
+			continue
+		}
+
+		old := *s
+		switch name {
+		case "emote-only":
+			s.EmoteOnly = value == "1"
+		case "followers-only":
+			if n, err := strconv.Atoi(value); err == nil {
+				s.FollowersOnly = n
+			}
+		case "r9k":
+			s.R9K = value == "1"
+		case "slow":
+			if n, err := strconv.Atoi(value); err == nil {
+				s.Slow = n
+			}
+		case "subs-only":
+			s.SubsOnly = value == "1"
+		}
+
+		if old != *s {
+			changed[name] = value
+		}
+	}
+
+	return changed
+}
+
+// OnRoomState adds an event callback for when the chat settings of a channel
+// are first reported, e.g., after joining it, or change. The callback receives
+// the complete room state along with the tags of the settings that changed, or
+// all of the tags of the first ROOMSTATE of the channel.
+func (c *Client) OnRoomState(callback func(channel string, state RoomState, changed map[string]string)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.roomStateCallbacks = append(c.roomStateCallbacks, callback)
+}
+
+// RoomState returns the last known chat settings of a channel. If the "#" prefix
+// is missing, it is automatically prepended. The second return value is false
+// if no ROOMSTATE has been received for the channel.
+func (c *Client) RoomState(channel string) (RoomState, bool) {
+	channel = channelName(channel)
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	state, ok := c.roomStates[channel]
+	return state, ok
+}
+
+func (c *Client) doRoomStateCallbacks(msg *Message) {
+	if len(msg.Params) < 1 {
+		return
+	}
+	channel := msg.Params[0]
+
+	c.stateMu.Lock()
+	if c.roomStates == nil {
+		c.roomStates = make(map[string]RoomState)
+	}
+	state, ok := c.roomStates[channel]
+	if !ok {
+		state = newRoomState()
+	}
+	changed := state.update(msg.Tags)
+	c.roomStates[channel] = state
+	c.stateMu.Unlock()
+
+	if !ok {
+		// The first ROOMSTATE reports the initial settings, even the default ones
+		changed = make(map[string]string)
+		for _, name := range append([]string{"room-id"}, roomStateTags...) {
+			if value, ok := msg.Tags[name]; ok {
+				changed[name] = value
+			}
+		}
+	} else if len(changed) == 0 {
+		return
+	}
+
+	c.callbackMu.Lock()
+	callbacks := c.roomStateCallbacks
+	c.callbackMu.Unlock()
+
+	for _, cb := range callbacks {
+		cb(channel, state, changed)
+	}
+}
@@ -0,0 +1,86 @@
+package gotirc
+
+import "testing"
+
+func TestOnRoomState(t *testing.T) {
+	client := NewClient(Options{})
+	expectedChan := "#test"
+	var gotChan string
+	var gotState RoomState
+	var gotChanged map[string]string
+	calls := 0
+	client.OnRoomState(func(channel string, state RoomState, changed map[string]string) {
+		calls++
+		gotChan, gotState, gotChanged = channel, state, changed
+	})
+
+	if _, ok := client.RoomState(expectedChan); ok {
+		t.Error("Expected 'false', got 'true'")
+	}
+
+	// Full state sent after joining
+	line := createMessage("ROOMSTATE", expectedChan, nil, map[string]string{
+		"emote-only":     "0",
+		"followers-only": "-1",
+		"r9k":            "0",
+		"room-id":        "12345",
+		"slow":           "0",
+		"subs-only":      "0",
+	})
+	client.doCallbacks(line)
+
+	if calls != 1 {
+		t.Errorf("Expected '1' call, got '%d'", calls)
+	}
+	if len(gotChanged) != 6 || gotChanged["slow"] != "0" || gotChanged["room-id"] != "12345" {
+		t.Errorf("Expected all of the tags, got '%v'", gotChanged)
+	}
+	state, ok := client.RoomState("test")
+	if !ok {
+		t.Error("Expected 'true', got 'false'")
+	}
+	expected := RoomState{RoomID: "12345", FollowersOnly: -1}
+	if state != expected {
+		t.Errorf("Expected '%+v', got '%+v'", expected, state)
+	}
+
+	// Slow mode enabled
+	line = createMessage("ROOMSTATE", expectedChan, nil, map[string]string{"room-id": "12345", "slow": "30"})
+	client.doCallbacks(line)
+
+	if calls != 2 {
+		t.Errorf("Expected '2' calls, got '%d'", calls)
+	}
+	if expectedChan != gotChan {
+		t.Errorf("Expected '%s', got '%s'", expectedChan, gotChan)
+	}
+	if gotState.Slow != 30 {
+		t.Errorf("Expected '30', got '%d'", gotState.Slow)
+	}
+	if len(gotChanged) != 1 || gotChanged["slow"] != "30" {
+		t.Errorf("Expected 'map[slow:30]', got '%v'", gotChanged)
+	}
+	if state, _ := client.RoomState(expectedChan); state.Slow != 30 {
+		t.Errorf("Expected '30', got '%d'", state.Slow)
+	}
+
+	// Unchanged setting
+	client.doCallbacks(line)
+	if calls != 2 {
+		t.Errorf("Expected '2' calls, got '%d'", calls)
+	}
+
+	// Followers-only and subs-only enabled
+	line = createMessage("ROOMSTATE", expectedChan, nil, map[string]string{"followers-only": "10", "subs-only": "1"})
+	client.doCallbacks(line)
+
+	if calls != 3 {
+		t.Errorf("Expected '3' calls, got '%d'", calls)
+	}
+	if gotState.FollowersOnly != 10 || !gotState.SubsOnly || gotState.Slow != 30 {
+		t.Errorf("Unexpected state '%+v'", gotState)
+	}
+	if len(gotChanged) != 2 {
+		t.Errorf("Expected '2' changes, got '%v'", gotChanged)
+	}
+}
@@ -0,0 +1,146 @@
+package gotirc
+
+import (
+	"sort"
+	"strings"
+)
+
+// OnNames adds an event callback for when the server has finished sending the
+// list of users in a channel (NAMES), usually right after joining it
+func (c *Client) OnNames(callback func(channel string, users []string)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.namesCallbacks = append(c.namesCallbacks, callback)
+}
+
+// Users returns the sorted usernames of the users known to be in a channel. If
+// the "#" prefix is missing, it is automatically prepended. The list is built
+// from the NAMES reply sent after joining and kept up to date with JOIN and PART
+// messages, which requires the twitch.tv/membership capability. The users of a
+// channel are forgotten when the client parts it.
+func (c *Client) Users(channel string) []string {
+	channel = channelName(channel)
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	return sortedUsers(c.rosters[channel])
+}
+
+// doNamesReply handles a 353 reply, which has the form
+// 353 <nick> = #channel :user1 user2 ...
+func (c *Client) doNamesReply(msg *Message) {
+	if len(msg.Params) < 4 {
+		return
+	}
+	channel := msg.Params[2]
+
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.pendingNames == nil {
+		c.pendingNames = make(map[string]map[string]struct{})
+	}
+	names, ok := c.pendingNames[channel]
+	if !ok {
+		names = make(map[string]struct{})
+		c.pendingNames[channel] = names
+	}
+	for _, user := range strings.Fields(msg.Params[3]) {
+		names[strings.ToLower(user)] = struct{}{}
+	}
+}
+
+// doEndOfNamesCallbacks handles a 366 reply, which has the form
+// 366 <nick> #channel :End of /NAMES list. The NAMES list replaces the users
+// previously known to be in the channel.
+func (c *Client) doEndOfNamesCallbacks(msg *Message) {
+	if len(msg.Params) < 2 {
+		return
+	}
+	channel := msg.Params[1]
+
+	c.stateMu.Lock()
+	if c.rosters == nil {
+		c.rosters = make(map[string]map[string]struct{})
+	}
+	roster := c.pendingNames[channel]
+	if roster == nil {
+		roster = make(map[string]struct{})
+	}
+	c.rosters[channel] = roster
+	delete(c.pendingNames, channel)
+	users := sortedUsers(roster)
+	c.stateMu.Unlock()
+
+	c.callbackMu.Lock()
+	callbacks := c.namesCallbacks
+	c.callbackMu.Unlock()
+
+	for _, cb := range callbacks {
+		cb(channel, users)
+	}
+}
+
+// addUser records that a user joined a channel
+func (c *Client) addUser(channel, user string) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	addChannelUser(&c.rosters, channel, user)
+}
+
+// removeUser records that a user parted a channel
+func (c *Client) removeUser(channel, user string) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	removeChannelUser(c.rosters, channel, user)
+}
+
+// forgetChannel forgets the users, moderators and VIPs of a channel, along with
+// its room state and the client's user state in it, e.g., when the client parts it
+func (c *Client) forgetChannel(channel string) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	delete(c.rosters, channel)
+	delete(c.pendingNames, channel)
+	delete(c.moderators, channel)
+	delete(c.vips, channel)
+	delete(c.roomStates, channel)
+	delete(c.userStates, channel)
+}
+
+// addChannelUser adds a user to the set of users of a channel and returns
+// whether the user was not already in the set
+func addChannelUser(sets *map[string]map[string]struct{}, channel, user string) bool {
+	if *sets == nil {
+		*sets = make(map[string]map[string]struct{})
+	}
+	users, ok := (*sets)[channel]
+	if !ok {
+		users = make(map[string]struct{})
+		(*sets)[channel] = users
+	}
+	user = strings.ToLower(user)
+	if _, ok := users[user]; ok {
+		return false
+	}
+	users[user] = struct{}{}
+	return true
+}
+
+// removeChannelUser removes a user from the set of users of a channel and
+// returns whether the user was in the set
+func removeChannelUser(sets map[string]map[string]struct{}, channel, user string) bool {
+	user = strings.ToLower(user)
+	if _, ok := sets[channel][user]; !ok {
+		return false
+	}
+	delete(sets[channel], user)
+	return true
+}
+
+func sortedUsers(roster map[string]struct{}) []string {
+	users := make([]string, 0, len(roster))
+	for user := range roster {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	return users
+}
@@ -0,0 +1,92 @@
+package gotirc
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOnNames(t *testing.T) {
+	client := NewClient(Options{})
+	expectedChan := "#test"
+	var gotChan string
+	var gotUsers []string
+	calls := 0
+	client.OnNames(func(channel string, users []string) {
+		calls++
+		gotChan, gotUsers = channel, users
+	})
+
+	client.doCallbacks(":" + username + ".tmi.twitch.tv 353 " + username + " = #test :user3 user1\r\n")
+	client.doCallbacks(":" + username + ".tmi.twitch.tv 353 " + username + " = #test :User2\r\n")
+
+	if calls != 0 {
+		t.Errorf("Expected '0' calls, got '%d'", calls)
+	}
+	if users := client.Users(expectedChan); len(users) != 0 {
+		t.Errorf("Expected '[]', got '%v'", users)
+	}
+
+	client.doCallbacks(":" + username + ".tmi.twitch.tv 366 " + username + " #test :End of /NAMES list\r\n")
+
+	expected := "user1 user2 user3"
+	if calls != 1 {
+		t.Errorf("Expected '1' call, got '%d'", calls)
+	}
+	if expectedChan != gotChan {
+		t.Errorf("Expected '%s', got '%s'", expectedChan, gotChan)
+	}
+	if got := strings.Join(gotUsers, " "); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+
+	client.doCallbacks(fmt.Sprintf(":%s!%s@%s.tmi.twitch.tv JOIN %s\r\n", "user4", "user4", "user4", expectedChan))
+	client.doCallbacks(fmt.Sprintf(":%s!%s@%s.tmi.twitch.tv PART %s\r\n", "user1", "user1", "user1", expectedChan))
+
+	expected = "user2 user3 user4"
+	if got := strings.Join(client.Users("test"), " "); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+	if users := client.Users("#other"); len(users) != 0 {
+		t.Errorf("Expected '[]', got '%v'", users)
+	}
+}
+
+func TestSelfPartForgetsChannel(t *testing.T) {
+	client := NewClient(Options{})
+	client.nick = "test_nick"
+	names := func(users string) {
+		client.doCallbacks(":" + username + ".tmi.twitch.tv 353 " + username + " = #test :" + users + "\r\n")
+		client.doCallbacks(":" + username + ".tmi.twitch.tv 366 " + username + " #test :End of /NAMES list\r\n")
+	}
+
+	names("test_nick user1 user2")
+	client.doCallbacks(":jtv MODE #test +o user1\r\n")
+	client.doCallbacks(createMessage("USERSTATE", "#test", nil, map[string]string{"mod": "1"}))
+	client.doCallbacks(createMessage("ROOMSTATE", "#test", nil, map[string]string{"slow": "30"}))
+	client.doCallbacks(":test_nick!test_nick@test_nick.tmi.twitch.tv PART #test\r\n")
+	if users := client.Users("#test"); len(users) != 0 {
+		t.Errorf("Expected '[]', got '%v'", users)
+	}
+	if client.IsModerator("#test", "user1") {
+		t.Error("Expected 'false', got 'true'")
+	}
+	if _, ok := client.SelfIn("#test"); ok {
+		t.Error("Expected 'false', got 'true'")
+	}
+	if _, ok := client.RoomState("#test"); ok {
+		t.Error("Expected 'false', got 'true'")
+	}
+	if interval := client.channelInterval("#test"); interval != time.Second {
+		t.Errorf("Expected '1s', got '%s'", interval)
+	}
+
+	// The NAMES list sent after rejoining replaces the users known before
+	client.doCallbacks(":user3!user3@user3.tmi.twitch.tv JOIN #test\r\n")
+	names("test_nick user2")
+	expected := "test_nick user2"
+	if got := strings.Join(client.Users("#test"), " "); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+}
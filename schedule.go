@@ -0,0 +1,99 @@
+package gotirc
+
+import "time"
+
+// channelQueues holds the messages waiting to be sent, queued per channel. The
+// channels take turns so that a busy channel can't delay the others, and each
+// channel may have a minimum interval between its messages.
+type channelQueues struct {
+	queues  map[string][]string
+	ring    []string // Channels with queued messages, in turn order
+	pos     int      // Index in ring of the channel whose turn is next
+	readyAt map[string]time.Time
+	size    int
+}
+
+func newChannelQueues() *channelQueues {
+	return &channelQueues{
+		queues:  make(map[string][]string),
+		readyAt: make(map[string]time.Time),
+	}
+}
+
+func (q *channelQueues) push(channel, msg string) {
+	if len(q.queues[channel]) == 0 {
+		q.ring = append(q.ring, channel)
+	}
+	q.queues[channel] = append(q.queues[channel], msg)
+	q.size++
+}
+
+// pop removes and returns the next message of the first channel, in turn order,
+// that may send at now. delay, if not nil, returns how long a channel must
+// still wait for reasons other than its interval, e.g., a rate limit, and such
+// channels are skipped. If no channel may send, ok is false and wait is the time
+// until one may, or 0 if no messages are queued.
+func (q *channelQueues) pop(now time.Time, delay func(channel string) time.Duration) (channel, msg string, ok bool, wait time.Duration) {
+	for i := 0; i < len(q.ring); i++ {
+		idx := (q.pos + i) % len(q.ring)
+		channel = q.ring[idx]
+		w := q.readyAt[channel].Sub(now)
+		if delay != nil {
+			if d := delay(channel); d > w {
+				w = d
+			}
+		}
+		if w > 0 {
+			if wait == 0 || w < wait {
+				wait = w
+			}
+			continue
+		}
+
+		queue := q.queues[channel]
+		msg = queue[0]
+		q.size--
+		if len(queue) == 1 {
+			delete(q.queues, channel)
+			q.ring = append(q.ring[:idx], q.ring[idx+1:]...)
+			q.pos = idx
+		} else {
+			q.queues[channel] = queue[1:]
+			q.pos = idx + 1
+		}
+		if len(q.ring) > 0 {
+			q.pos %= len(q.ring)
+		} else {
+			q.pos = 0
+		}
+		return channel, msg, true, 0
+	}
+	return "", "", false, wait
+}
+
+// sent records that a message was sent to a channel at the given time, and that
+// the channel's next message may not be sent until interval has passed
+func (q *channelQueues) sent(channel string, at time.Time, interval time.Duration) {
+	if interval <= 0 {
+		delete(q.readyAt, channel)
+		return
+	}
+	q.readyAt[channel] = at.Add(interval)
+}
+
+// channelInterval returns the minimum time between messages sent to a channel.
+// Twitch allows one message per second in channels where the client is not a
+// moderator, or fewer if the channel is in slow mode.
+func (c *Client) channelInterval(channel string) time.Duration {
+	if channel == "" || channel == "#jtv" || c.isSelfModerator(channel) {
+		return 0
+	}
+
+	interval := time.Second
+	if state, ok := c.RoomState(channel); ok {
+		if slow := time.Duration(state.Slow) * time.Second; slow > interval {
+			interval = slow
+		}
+	}
+	return interval
+}
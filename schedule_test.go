@@ -0,0 +1,110 @@
+package gotirc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChannelQueues(t *testing.T) {
+	q := newChannelQueues()
+	now := time.Now()
+
+	if _, _, ok, wait := q.pop(now, nil); ok || wait != 0 {
+		t.Errorf("Expected 'false' and '0', got '%t' and '%s'", ok, wait)
+	}
+
+	// A busy channel takes turns with the others
+	q.push("#busy", "b1")
+	q.push("#busy", "b2")
+	q.push("#busy", "b3")
+	q.push("#quiet", "q1")
+	q.push("", "PONG")
+
+	expected := []string{"b1", "q1", "PONG", "b2", "b3"}
+	for _, e := range expected {
+		_, msg, ok, _ := q.pop(now, nil)
+		if !ok || msg != e {
+			t.Errorf("Expected '%s', got '%s'", e, msg)
+		}
+	}
+	if q.size != 0 {
+		t.Errorf("Expected '0', got '%d'", q.size)
+	}
+
+	// Channels wait for their interval
+	q.push("#slow", "s1")
+	q.push("#slow", "s2")
+	q.push("#fast", "f1")
+
+	channel, msg, _, _ := q.pop(now, nil)
+	q.sent(channel, now, 30*time.Second)
+	if msg != "s1" {
+		t.Errorf("Expected 's1', got '%s'", msg)
+	}
+
+	channel, msg, _, _ = q.pop(now, nil)
+	q.sent(channel, now, 0)
+	if msg != "f1" {
+		t.Errorf("Expected 'f1', got '%s'", msg)
+	}
+
+	_, _, ok, wait := q.pop(now.Add(10*time.Second), nil)
+	if ok || wait != 20*time.Second {
+		t.Errorf("Expected 'false' and '20s', got '%t' and '%s'", ok, wait)
+	}
+
+	_, msg, ok, _ = q.pop(now.Add(30*time.Second), nil)
+	if !ok || msg != "s2" {
+		t.Errorf("Expected 's2', got '%s'", msg)
+	}
+}
+
+func TestChannelQueuesDelay(t *testing.T) {
+	q := newChannelQueues()
+	now := time.Now()
+	q.push("#a", "a1")
+	q.push("#b", "b1")
+	q.push("#c", "c1")
+
+	// Channels that must wait are skipped, and the shortest wait is returned
+	// once no channel may send
+	delays := map[string]time.Duration{"#a": 5 * time.Second, "#c": 3 * time.Second}
+	delay := func(channel string) time.Duration { return delays[channel] }
+	if _, msg, ok, _ := q.pop(now, delay); !ok || msg != "b1" {
+		t.Errorf("Expected 'b1', got '%s'", msg)
+	}
+	if _, _, ok, wait := q.pop(now, delay); ok || wait != 3*time.Second {
+		t.Errorf("Expected 'false' and '3s', got '%t' and '%s'", ok, wait)
+	}
+
+	// The longer of the delay and the channel's interval applies
+	q.sent("#c", now, 10*time.Second)
+	delete(delays, "#a")
+	if _, msg, ok, _ := q.pop(now, delay); !ok || msg != "a1" {
+		t.Errorf("Expected 'a1', got '%s'", msg)
+	}
+	if _, _, ok, wait := q.pop(now, delay); ok || wait != 10*time.Second {
+		t.Errorf("Expected 'false' and '10s', got '%t' and '%s'", ok, wait)
+	}
+}
+
+func TestChannelInterval(t *testing.T) {
+	client := NewClient(Options{})
+
+	if interval := client.channelInterval(""); interval != 0 {
+		t.Errorf("Expected '0s', got '%s'", interval)
+	}
+	if interval := client.channelInterval("#test"); interval != time.Second {
+		t.Errorf("Expected '1s', got '%s'", interval)
+	}
+
+	client.doCallbacks(createMessage("ROOMSTATE", "#test", nil, map[string]string{"slow": "30"}))
+	if interval := client.channelInterval("#test"); interval != 30*time.Second {
+		t.Errorf("Expected '30s', got '%s'", interval)
+	}
+
+	client.doCallbacks(createMessage("USERSTATE", "#test", nil, map[string]string{"badges": "moderator/1", "mod": "1"}))
+	if interval := client.channelInterval("#test"); interval != 0 {
+		t.Errorf("Expected '0s', got '%s'", interval)
+	}
+}
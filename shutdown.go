@@ -0,0 +1,47 @@
+package gotirc
+
+import "context"
+
+// Shutdown gracefully disconnects the client. Sending new messages fails with
+// ErrShuttingDown, the messages already queued are sent as the rate limits allow,
+// and QUIT is sent before the connection is closed. Control messages, such as
+// the PONGs that keep the connection alive, are still sent in the meantime.
+// Channels waiting to be joined are not joined. If ctx is done first, the
+// connection is closed immediately, discarding the remaining messages, and
+// ctx.Err() is returned.
+func (c *Client) Shutdown(ctx context.Context) error {
+	// Lock sendMu first so that no messages are being queued, see enqueue
+	c.sendMu.Lock()
+	c.connectedMu.Lock()
+	if c.state != StateConnected && (c.state != StateClosing || !c.shuttingDown) {
+		c.connectedMu.Unlock()
+		c.sendMu.Unlock()
+		return ErrNotConnected
+	}
+	done, flushed := c.doneChan, c.flushedChan
+	if !c.shuttingDown {
+		c.shuttingDown = true
+		c.changeState(StateClosing)
+		close(c.shutdownChan)
+	}
+	c.connectedMu.Unlock()
+	c.sendMu.Unlock()
+
+	c.deliverStateChanges()
+
+	select {
+	case <-flushed:
+		return nil
+	case <-done:
+		// The send loop closes flushed before disconnecting
+		select {
+		case <-flushed:
+			return nil
+		default:
+			return ErrNotConnected
+		}
+	case <-ctx.Done():
+		c.Disconnect()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,98 @@
+package gotirc
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestShutdown(t *testing.T) {
+	var wg sync.WaitGroup
+	client, server := createClientServer()
+	clock := newFakeClock()
+	client.options.Clock = clock
+	client.sendQueue = make(chan string, sendBufferSize)
+	client.state = StateConnected
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		client.startSendLoop(10, 1)
+	}()
+
+	lines := readLines(server)
+	client.Say("test", "a")
+	client.Say("test", "b")
+	if line := <-lines; line != "PRIVMSG #test :a\r\n" {
+		t.Errorf("Expected 'PRIVMSG #test :a', got '%s'", line)
+	}
+
+	result := make(chan error)
+	go func() {
+		result <- client.Shutdown(context.Background())
+	}()
+	for client.State() != StateClosing {
+		runtime.Gosched()
+	}
+
+	// New messages are refused, queued ones are still sent
+	if err := client.Say("test", "c"); err != ErrShuttingDown {
+		t.Errorf("Expected '%s', got '%v'", ErrShuttingDown, err)
+	}
+	if err := client.Join("other"); err != ErrShuttingDown {
+		t.Errorf("Expected '%s', got '%v'", ErrShuttingDown, err)
+	}
+
+	// The connection is kept alive while the queue is flushed
+	client.doCallbacks("PING :tmi.twitch.tv")
+	if line := <-lines; line != "PONG :tmi.twitch.tv\r\n" {
+		t.Errorf("Expected 'PONG :tmi.twitch.tv', got '%s'", line)
+	}
+	clock.step() // Channel interval
+	expected := []string{"PRIVMSG #test :b\r\n", "QUIT\r\n"}
+	for _, e := range expected {
+		if line := <-lines; line != e {
+			t.Errorf("Expected '%s', got '%s'", e, line)
+		}
+	}
+
+	if err := <-result; err != nil {
+		t.Errorf("Expected 'nil', got '%s'", err)
+	}
+	wg.Wait()
+	if client.Connected() {
+		t.Error("Expected 'false', got 'true'")
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	var wg sync.WaitGroup
+	client, server := createClientServer()
+	clock := newFakeClock()
+	client.options.Clock = clock
+	client.sendQueue = make(chan string, sendBufferSize)
+	client.state = StateConnected
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		client.startSendLoop(10, 1)
+	}()
+
+	lines := readLines(server)
+	client.Say("test", "a")
+	client.Say("test", "b")
+	<-lines
+
+	// The clock is never advanced, so "b" can't be sent before the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := client.Shutdown(ctx); err != context.Canceled {
+		t.Errorf("Expected '%s', got '%v'", context.Canceled, err)
+	}
+	wg.Wait()
+	if client.Connected() {
+		t.Error("Expected 'false', got 'true'")
+	}
+}
@@ -0,0 +1,83 @@
+package gotirc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxMessageLength is the maximum number of characters Twitch accepts in the
+// body of a PRIVMSG
+const maxMessageLength = 500
+
+// splitMessage splits msg into parts of at most limit characters, breaking on
+// spaces so that words (and therefore emotes) are kept whole. Words longer than
+// limit are broken between runes. If numbered is true and msg is split, each
+// part is prefixed with its position, e.g., "(1/3) ".
+func splitMessage(msg string, limit int, numbered bool) []string {
+	if utf8.RuneCountInString(msg) <= limit {
+		return []string{msg}
+	}
+	if !numbered {
+		return wrapWords(msg, limit)
+	}
+
+	for width := 1; ; width++ {
+		prefixLen := len("(/) ") + 2*width
+		if limit-prefixLen < 1 {
+			return wrapWords(msg, limit)
+		}
+
+		parts := wrapWords(msg, limit-prefixLen)
+		if len(strconv.Itoa(len(parts))) > width {
+			continue
+		}
+
+		for i := range parts {
+			parts[i] = fmt.Sprintf("(%d/%d) %s", i+1, len(parts), parts[i])
+		}
+		return parts
+	}
+}
+
+// wrapWords breaks msg into lines of at most limit characters
+func wrapWords(msg string, limit int) []string {
+	var parts []string
+	var line strings.Builder
+	lineLen := 0
+
+	for _, word := range strings.Split(msg, " ") {
+		wordLen := utf8.RuneCountInString(word)
+		if lineLen > 0 && lineLen+1+wordLen <= limit {
+			line.WriteByte(' ')
+			line.WriteString(word)
+			lineLen += 1 + wordLen
+			continue
+		}
+
+		if lineLen > 0 {
+			parts = append(parts, line.String())
+			line.Reset()
+			lineLen = 0
+		}
+
+		for wordLen > limit {
+			i := 0
+			for n := 0; n < limit; n++ {
+				_, size := utf8.DecodeRuneInString(word[i:])
+				i += size
+			}
+			parts = append(parts, word[:i])
+			word = word[i:]
+			wordLen -= limit
+		}
+		line.WriteString(word)
+		lineLen = wordLen
+	}
+
+	if lineLen > 0 {
+		parts = append(parts, line.String())
+	}
+	return parts
+}
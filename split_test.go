@@ -0,0 +1,52 @@
+package gotirc
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitMessage(t *testing.T) {
+	parts := splitMessage("short message", 20, true)
+	if len(parts) != 1 || parts[0] != "short message" {
+		t.Errorf("Expected '[short message]', got '%q'", parts)
+	}
+
+	parts = splitMessage("one two three four five", 9, false)
+	expected := []string{"one two", "three", "four five"}
+	if strings.Join(parts, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected '%q', got '%q'", expected, parts)
+	}
+
+	// Words longer than the limit are broken between runes
+	parts = splitMessage("ab ééééé cd", 3, false)
+	expected = []string{"ab", "ééé", "éé", "cd"}
+	if strings.Join(parts, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected '%q', got '%q'", expected, parts)
+	}
+
+	parts = splitMessage("Kappa Kappa Kappa Kappa", 17, true)
+	expected = []string{"(1/2) Kappa Kappa", "(2/2) Kappa Kappa"}
+	if strings.Join(parts, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected '%q', got '%q'", expected, parts)
+	}
+
+	msg := strings.TrimSpace(strings.Repeat("PogChamp 日本語 ", 200))
+	parts = splitMessage(msg, maxMessageLength, true)
+	if len(parts) != 6 {
+		t.Errorf("Expected '6' parts, got '%d'", len(parts))
+	}
+	for _, p := range parts {
+		if n := utf8.RuneCountInString(p); n > maxMessageLength {
+			t.Errorf("Expected at most '%d' characters, got '%d'", maxMessageLength, n)
+		}
+		if !utf8.ValidString(p) {
+			t.Errorf("Expected valid UTF-8, got '%q'", p)
+		}
+		for _, word := range strings.Fields(p)[1:] {
+			if word != "PogChamp" && word != "日本語" {
+				t.Errorf("Expected whole words, got '%s'", word)
+			}
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package gotirc
+
+import "strconv"
+
+// OnRaid adds an event callback for when a user raids a channel. raider is the
+// display name of the raiding broadcaster and viewers is the size of the raid.
+func (c *Client) OnRaid(callback func(channel, raider string, viewers int, tags map[string]string)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.raidCallbacks = append(c.raidCallbacks, callback)
+}
+
+// OnUnraid adds an event callback for when a raid of a channel is cancelled
+func (c *Client) OnUnraid(callback func(channel string, tags map[string]string)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.unraidCallbacks = append(c.unraidCallbacks, callback)
+}
+
+// OnRitual adds an event callback for rituals, such as when a new chatter sends
+// their first message in a channel. The ritual name is in the
+// msg-param-ritual-name tag.
+func (c *Client) OnRitual(callback func(channel string, tags map[string]string, msg string)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.ritualCallbacks = append(c.ritualCallbacks, callback)
+}
+
+// OnAnnouncement adds an event callback for when a moderator sends an
+// announcement to a channel. color is the highlight color of the announcement
+// (e.g., PRIMARY, BLUE).
+func (c *Client) OnAnnouncement(callback func(channel, color string, tags map[string]string, msg string)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.announcementCallbacks = append(c.announcementCallbacks, callback)
+}
+
+// OnBitsBadgeTier adds an event callback for when a user earns a new bits badge
+// tier in a channel. threshold is the number of bits of the new tier.
+func (c *Client) OnBitsBadgeTier(callback func(channel string, threshold int, tags map[string]string, msg string)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.bitsBadgeTierCallbacks = append(c.bitsBadgeTierCallbacks, callback)
+}
+
+// OnViewerMilestone adds an event callback for when a user reaches a viewer
+// milestone (e.g., a watch streak) in a channel. The milestone is described by
+// the msg-param-category and msg-param-value tags.
+func (c *Client) OnViewerMilestone(callback func(channel string, tags map[string]string, msg string)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.viewerMilestoneCallbacks = append(c.viewerMilestoneCallbacks, callback)
+}
+
+// OnMysteryGift adds an event callback for when a user gifts a number of
+// subscriptions to random users in a channel. The individual gifts follow as
+// subgift (or anonsubgift) notices that share the msg-param-origin-id tag.
+func (c *Client) OnMysteryGift(callback func(channel string, count int, tags map[string]string)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.mysteryGiftCallbacks = append(c.mysteryGiftCallbacks, callback)
+}
+
+// OnAnonSubGift adds an event callback for when an anonymous user gifts a
+// subscription to another user in a channel
+func (c *Client) OnAnonSubGift(callback func(channel string, tags map[string]string, msg string)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.anonSubGiftCallbacks = append(c.anonSubGiftCallbacks, callback)
+}
+
+// OnGiftPaidUpgrade adds an event callback for when a user continues a gifted
+// subscription they received from another user
+func (c *Client) OnGiftPaidUpgrade(callback func(channel string, tags map[string]string, msg string)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.giftPaidUpgradeCallbacks = append(c.giftPaidUpgradeCallbacks, callback)
+}
+
+// OnAnonGiftPaidUpgrade adds an event callback for when a user continues a
+// gifted subscription they received from an anonymous user
+func (c *Client) OnAnonGiftPaidUpgrade(callback func(channel string, tags map[string]string, msg string)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.anonGiftPaidUpgradeCallbacks = append(c.anonGiftPaidUpgradeCallbacks, callback)
+}
+
+// OnPrimePaidUpgrade adds an event callback for when a user converts a Prime
+// subscription to a paid subscription
+func (c *Client) OnPrimePaidUpgrade(callback func(channel string, tags map[string]string, msg string)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.primePaidUpgradeCallbacks = append(c.primePaidUpgradeCallbacks, callback)
+}
+
+// OnUserNotice adds an event callback for USERNOTICE messages whose msg-id is not
+// handled by any other callback
+func (c *Client) OnUserNotice(callback func(channel, msgID string, tags map[string]string, msg string)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.userNoticeCallbacks = append(c.userNoticeCallbacks, callback)
+}
+
+func (c *Client) doUserNoticeCallbacks(msg *Message) {
+	if len(msg.Params) < 1 {
+		return
+	}
+
+	switch msg.Tags["msg-id"] {
+	case "resub":
+		c.doResubCallbacks(msg)
+	case "sub":
+		c.doSubscriptionCallbacks(msg)
+	case "subgift":
+		c.doSubGiftCallbacks(msg)
+		c.addGiftBombGift(msg)
+	case "anonsubgift":
+		c.doTagCallbacks(msg, &c.anonSubGiftCallbacks)
+		c.addGiftBombGift(msg)
+	case "submysterygift":
+		c.doMysteryGiftCallbacks(msg)
+		c.startGiftBomb(msg)
+	case "giftpaidupgrade":
+		c.doTagCallbacks(msg, &c.giftPaidUpgradeCallbacks)
+	case "anongiftpaidupgrade":
+		c.doTagCallbacks(msg, &c.anonGiftPaidUpgradeCallbacks)
+	case "primepaidupgrade":
+		c.doTagCallbacks(msg, &c.primePaidUpgradeCallbacks)
+	case "raid":
+		c.doRaidCallbacks(msg)
+	case "unraid":
+		c.doUnraidCallbacks(msg)
+	case "ritual":
+		c.doRitualCallbacks(msg)
+	case "announcement":
+		c.doAnnouncementCallbacks(msg)
+	case "bitsbadgetier":
+		c.doBitsBadgeTierCallbacks(msg)
+	case "viewermilestone":
+		c.doViewerMilestoneCallbacks(msg)
+	default:
+		c.doUnknownUserNoticeCallbacks(msg)
+	}
+}
+
+func (c *Client) doRaidCallbacks(msg *Message) {
+	c.callbackMu.Lock()
+	callbacks := c.raidCallbacks
+	c.callbackMu.Unlock()
+
+	viewers, _ := strconv.Atoi(msg.Tags["msg-param-viewerCount"])
+	raider := msg.Tags["msg-param-displayName"]
+	for _, cb := range callbacks {
+		cb(msg.Params[0], raider, viewers, msg.Tags)
+	}
+}
+
+func (c *Client) doUnraidCallbacks(msg *Message) {
+	c.callbackMu.Lock()
+	callbacks := c.unraidCallbacks
+	c.callbackMu.Unlock()
+
+	for _, cb := range callbacks {
+		cb(msg.Params[0], msg.Tags)
+	}
+}
+
+func (c *Client) doRitualCallbacks(msg *Message) {
+	c.callbackMu.Lock()
+	callbacks := c.ritualCallbacks
+	c.callbackMu.Unlock()
+
+	m := userNoticeMessage(msg)
+	for _, cb := range callbacks {
+		cb(msg.Params[0], msg.Tags, m)
+	}
+}
+
+func (c *Client) doAnnouncementCallbacks(msg *Message) {
+	c.callbackMu.Lock()
+	callbacks := c.announcementCallbacks
+	c.callbackMu.Unlock()
+
+	m := userNoticeMessage(msg)
+	color := msg.Tags["msg-param-color"]
+	for _, cb := range callbacks {
+		cb(msg.Params[0], color, msg.Tags, m)
+	}
+}
+
+func (c *Client) doBitsBadgeTierCallbacks(msg *Message) {
+	c.callbackMu.Lock()
+	callbacks := c.bitsBadgeTierCallbacks
+	c.callbackMu.Unlock()
+
+	m := userNoticeMessage(msg)
+	threshold, _ := strconv.Atoi(msg.Tags["msg-param-threshold"])
+	for _, cb := range callbacks {
+		cb(msg.Params[0], threshold, msg.Tags, m)
+	}
+}
+
+func (c *Client) doViewerMilestoneCallbacks(msg *Message) {
+	c.callbackMu.Lock()
+	callbacks := c.viewerMilestoneCallbacks
+	c.callbackMu.Unlock()
+
+	m := userNoticeMessage(msg)
+	for _, cb := range callbacks {
+		cb(msg.Params[0], msg.Tags, m)
+	}
+}
+
+func (c *Client) doMysteryGiftCallbacks(msg *Message) {
+	c.callbackMu.Lock()
+	callbacks := c.mysteryGiftCallbacks
+	c.callbackMu.Unlock()
+
+	count, _ := strconv.Atoi(msg.Tags["msg-param-mass-gift-count"])
+	for _, cb := range callbacks {
+		cb(msg.Params[0], count, msg.Tags)
+	}
+}
+
+// doTagCallbacks runs the callbacks stored in the given Client field, passing the
+// channel, tags and optional user message of a USERNOTICE
+func (c *Client) doTagCallbacks(msg *Message, field *[]func(channel string, tags map[string]string, msg string)) {
+	c.callbackMu.Lock()
+	callbacks := *field
+	c.callbackMu.Unlock()
+
+	m := userNoticeMessage(msg)
+	for _, cb := range callbacks {
+		cb(msg.Params[0], msg.Tags, m)
+	}
+}
+
+func (c *Client) doUnknownUserNoticeCallbacks(msg *Message) {
+	c.callbackMu.Lock()
+	callbacks := c.userNoticeCallbacks
+	c.callbackMu.Unlock()
+
+	m := userNoticeMessage(msg)
+	msgID := msg.Tags["msg-id"]
+	for _, cb := range callbacks {
+		cb(msg.Params[0], msgID, msg.Tags, m)
+	}
+}
+
+// userNoticeMessage returns the optional user message of a USERNOTICE
+func userNoticeMessage(msg *Message) string {
+	if len(msg.Params) > 1 {
+		return msg.Params[1]
+	}
+	return ""
+}
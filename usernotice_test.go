@@ -0,0 +1,206 @@
+package gotirc
+
+import "testing"
+
+func TestOnRaid(t *testing.T) {
+	client := NewClient(Options{})
+	expectedChan := "#test"
+	var gotChan, gotRaider string
+	var gotViewers int
+	client.OnRaid(func(channel, raider string, viewers int, tags map[string]string) {
+		gotChan, gotRaider, gotViewers = channel, raider, viewers
+	})
+
+	line := createMessage("USERNOTICE", expectedChan, nil, map[string]string{
+		"msg-id":                "raid",
+		"msg-param-displayName": "Raider1337",
+		"msg-param-login":       "raider1337",
+		"msg-param-viewerCount": "42",
+	})
+	client.doCallbacks(line)
+
+	if expectedChan != gotChan {
+		t.Errorf("Expected '%s', got '%s'", expectedChan, gotChan)
+	}
+	if gotRaider != "Raider1337" {
+		t.Errorf("Expected 'Raider1337', got '%s'", gotRaider)
+	}
+	if gotViewers != 42 {
+		t.Errorf("Expected '42', got '%d'", gotViewers)
+	}
+
+	unraided := false
+	client.OnUnraid(func(channel string, tags map[string]string) {
+		unraided = channel == expectedChan
+	})
+	client.doCallbacks(createMessage("USERNOTICE", expectedChan, nil, map[string]string{"msg-id": "unraid"}))
+	if !unraided {
+		t.Error("Expected 'true', got 'false'")
+	}
+}
+
+func TestOnRitual(t *testing.T) {
+	client := NewClient(Options{})
+	expectedMsg := "HeyGuys"
+	var gotRitual, gotMsg string
+	client.OnRitual(func(channel string, tags map[string]string, msg string) {
+		gotRitual, gotMsg = tags["msg-param-ritual-name"], msg
+	})
+
+	line := createMessage("USERNOTICE", "#test", []string{expectedMsg}, map[string]string{
+		"msg-id":                "ritual",
+		"msg-param-ritual-name": "new_chatter",
+	})
+	client.doCallbacks(line)
+
+	if gotRitual != "new_chatter" {
+		t.Errorf("Expected 'new_chatter', got '%s'", gotRitual)
+	}
+	if expectedMsg != gotMsg {
+		t.Errorf("Expected '%s', got '%s'", expectedMsg, gotMsg)
+	}
+}
+
+func TestOnAnnouncement(t *testing.T) {
+	client := NewClient(Options{})
+	expectedMsg := "Test announcement!"
+	var gotColor, gotMsg string
+	client.OnAnnouncement(func(channel, color string, tags map[string]string, msg string) {
+		gotColor, gotMsg = color, msg
+	})
+
+	line := createMessage("USERNOTICE", "#test", []string{expectedMsg}, map[string]string{
+		"msg-id":          "announcement",
+		"msg-param-color": "PRIMARY",
+	})
+	client.doCallbacks(line)
+
+	if gotColor != "PRIMARY" {
+		t.Errorf("Expected 'PRIMARY', got '%s'", gotColor)
+	}
+	if expectedMsg != gotMsg {
+		t.Errorf("Expected '%s', got '%s'", expectedMsg, gotMsg)
+	}
+}
+
+func TestOnBitsBadgeTier(t *testing.T) {
+	client := NewClient(Options{})
+	var gotThreshold int
+	client.OnBitsBadgeTier(func(channel string, threshold int, tags map[string]string, msg string) {
+		gotThreshold = threshold
+	})
+
+	line := createMessage("USERNOTICE", "#test", nil, map[string]string{
+		"msg-id":              "bitsbadgetier",
+		"msg-param-threshold": "10000",
+	})
+	client.doCallbacks(line)
+
+	if gotThreshold != 10000 {
+		t.Errorf("Expected '10000', got '%d'", gotThreshold)
+	}
+}
+
+func TestOnViewerMilestone(t *testing.T) {
+	client := NewClient(Options{})
+	var gotCategory, gotValue string
+	client.OnViewerMilestone(func(channel string, tags map[string]string, msg string) {
+		gotCategory, gotValue = tags["msg-param-category"], tags["msg-param-value"]
+	})
+
+	line := createMessage("USERNOTICE", "#test", nil, map[string]string{
+		"msg-id":             "viewermilestone",
+		"msg-param-category": "watch-streak",
+		"msg-param-value":    "5",
+	})
+	client.doCallbacks(line)
+
+	if gotCategory != "watch-streak" || gotValue != "5" {
+		t.Errorf("Expected 'watch-streak' and '5', got '%s' and '%s'", gotCategory, gotValue)
+	}
+}
+
+func TestOnUserNotice(t *testing.T) {
+	client := NewClient(Options{})
+	expectedChan := "#test"
+	expectedMsg := "Test message!"
+	var gotChan, gotID, gotMsg string
+	calls := 0
+	client.OnUserNotice(func(channel, msgID string, tags map[string]string, msg string) {
+		calls++
+		gotChan, gotID, gotMsg = channel, msgID, msg
+	})
+
+	// Known msg-ids are not passed to the catch-all callback
+	client.doCallbacks(createMessage("USERNOTICE", expectedChan, nil, map[string]string{"msg-id": "sub"}))
+	client.doCallbacks(createMessage("USERNOTICE", expectedChan, nil, map[string]string{"msg-id": "raid"}))
+	if calls != 0 {
+		t.Errorf("Expected '0' calls, got '%d'", calls)
+	}
+
+	line := createMessage("USERNOTICE", expectedChan, []string{expectedMsg}, map[string]string{"msg-id": "somethingnew"})
+	client.doCallbacks(line)
+
+	if calls != 1 {
+		t.Errorf("Expected '1' call, got '%d'", calls)
+	}
+	if expectedChan != gotChan {
+		t.Errorf("Expected '%s', got '%s'", expectedChan, gotChan)
+	}
+	if gotID != "somethingnew" {
+		t.Errorf("Expected 'somethingnew', got '%s'", gotID)
+	}
+	if expectedMsg != gotMsg {
+		t.Errorf("Expected '%s', got '%s'", expectedMsg, gotMsg)
+	}
+}
+
+func TestOnMysteryGift(t *testing.T) {
+	client := NewClient(Options{})
+	expectedChan := "#test"
+	var gotChan string
+	var gotCount int
+	client.OnMysteryGift(func(channel string, count int, tags map[string]string) {
+		gotChan, gotCount = channel, count
+	})
+
+	line := createMessage("USERNOTICE", expectedChan, nil, map[string]string{
+		"msg-id":                    "submysterygift",
+		"msg-param-mass-gift-count": "5",
+		"msg-param-origin-id":       "abc",
+	})
+	client.doCallbacks(line)
+
+	if expectedChan != gotChan {
+		t.Errorf("Expected '%s', got '%s'", expectedChan, gotChan)
+	}
+	if gotCount != 5 {
+		t.Errorf("Expected '5', got '%d'", gotCount)
+	}
+}
+
+func TestOnGiftUpgrades(t *testing.T) {
+	client := NewClient(Options{})
+	var got []string
+	record := func(channel string, tags map[string]string, msg string) {
+		got = append(got, tags["msg-id"])
+	}
+	client.OnAnonSubGift(record)
+	client.OnGiftPaidUpgrade(record)
+	client.OnAnonGiftPaidUpgrade(record)
+	client.OnPrimePaidUpgrade(record)
+
+	expected := []string{"anonsubgift", "giftpaidupgrade", "anongiftpaidupgrade", "primepaidupgrade"}
+	for _, id := range expected {
+		client.doCallbacks(createMessage("USERNOTICE", "#test", nil, map[string]string{"msg-id": id}))
+	}
+
+	if len(got) != len(expected) {
+		t.Fatalf("Expected '%v', got '%v'", expected, got)
+	}
+	for i := range expected {
+		if expected[i] != got[i] {
+			t.Errorf("Expected '%s', got '%s'", expected[i], got[i])
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package gotirc
+
+import "strings"
+
+// UserState holds the client's own user information as reported by
+// GLOBALUSERSTATE and USERSTATE
+type UserState struct {
+	UserID      string
+	DisplayName string
+	Color       string
+	Badges      map[string]string
+	EmoteSets   []string
+	Mod         bool
+	VIP         bool
+	Broadcaster bool
+	Subscriber  bool
+	ClientNonce string // client-nonce of the message that caused a USERSTATE, if any
+}
+
+func newUserState(tags map[string]string) UserState {
+	s := UserState{
+		UserID:      tags["user-id"],
+		DisplayName: tags["display-name"],
+		Color:       tags["color"],
+		Badges:      parseBadges(tags["badges"]),
+		Subscriber:  tags["subscriber"] == "1",
+		ClientNonce: tags["client-nonce"],
+	}
+	if sets := tags["emote-sets"]; sets != "" {
+		s.EmoteSets = strings.Split(sets, ",")
+	}
+
+	_, s.Broadcaster = s.Badges["broadcaster"]
+	_, s.VIP = s.Badges["vip"]
+	_, hasModBadge := s.Badges["moderator"]
+	s.Mod = tags["mod"] == "1" || hasModBadge
+	return s
+}
+
+// parseBadges parses a badges tag (e.g., moderator/1,subscriber/12) into a map of
+// badge names to versions
+func parseBadges(tag string) map[string]string {
+	badges := make(map[string]string)
+	if tag == "" {
+		return badges
+	}
+
+	for _, b := range strings.Split(tag, ",") {
+		badge := strings.SplitN(b, "/", 2)
+		if len(badge) < 2 {
+			badges[badge[0]] = ""
+		} else {
+			badges[badge[0]] = badge[1]
+		}
+	}
+	return badges
+}
+
+// OnUserState adds an event callback for when the server reports the client's
+// own user information. channel is empty for GLOBALUSERSTATE.
+func (c *Client) OnUserState(callback func(channel string, state UserState)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.userStateCallbacks = append(c.userStateCallbacks, callback)
+}
+
+// OnSelfModChange adds an event callback for when the client gains or loses
+// moderator status in a channel
+func (c *Client) OnSelfModChange(callback func(channel string, mod bool)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.selfModCallbacks = append(c.selfModCallbacks, callback)
+}
+
+// Self returns the client's own user information as reported by GLOBALUSERSTATE
+func (c *Client) Self() UserState {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	return c.globalUserState
+}
+
+// SelfIn returns the client's own user information in a channel as reported by
+// USERSTATE. If the "#" prefix is missing, it is automatically prepended. The
+// second return value is false if no USERSTATE has been received for the channel.
+func (c *Client) SelfIn(channel string) (UserState, bool) {
+	channel = channelName(channel)
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	state, ok := c.userStates[channel]
+	return state, ok
+}
+
+func (c *Client) doGlobalUserStateCallbacks(msg *Message) {
+	state := newUserState(msg.Tags)
+
+	c.stateMu.Lock()
+	c.globalUserState = state
+	c.stateMu.Unlock()
+
+	c.callbackMu.Lock()
+	callbacks := c.userStateCallbacks
+	c.callbackMu.Unlock()
+
+	for _, cb := range callbacks {
+		cb("", state)
+	}
+}
+
+func (c *Client) doUserStateCallbacks(msg *Message) {
+	if len(msg.Params) < 1 {
+		return
+	}
+	channel := msg.Params[0]
+	state := newUserState(msg.Tags)
+
+	c.stateMu.Lock()
+	if state.UserID == "" {
+		state.UserID = c.globalUserState.UserID
+	}
+	if c.userStates == nil {
+		c.userStates = make(map[string]UserState)
+	}
+	old := c.userStates[channel]
+	c.userStates[channel] = state
+	c.stateMu.Unlock()
+
+	c.pendingSays.acknowledge(channel, state.ClientNonce)
+
+	c.callbackMu.Lock()
+	callbacks := c.userStateCallbacks
+	modCallbacks := c.selfModCallbacks
+	c.callbackMu.Unlock()
+
+	for _, cb := range callbacks {
+		cb(channel, state)
+	}
+
+	if old.Mod != state.Mod {
+		for _, cb := range modCallbacks {
+			cb(channel, state.Mod)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package gotirc
+
+import "testing"
+
+func TestParseBadges(t *testing.T) {
+	badges := parseBadges("")
+	if len(badges) != 0 {
+		t.Errorf("Expected '0' badges, got '%d'", len(badges))
+	}
+
+	badges = parseBadges("moderator/1,subscriber/12,premium")
+	expected := map[string]string{"moderator": "1", "subscriber": "12", "premium": ""}
+	if len(badges) != len(expected) {
+		t.Errorf("Expected '%v', got '%v'", expected, badges)
+	}
+	for k := range expected {
+		if v, ok := badges[k]; !ok || v != expected[k] {
+			t.Errorf("Expected '%s', got '%s'", expected[k], v)
+		}
+	}
+}
+
+func TestOnUserState(t *testing.T) {
+	client := NewClient(Options{})
+	expectedChan := "#test"
+	var gotChans []string
+	var gotModChan string
+	var gotMod bool
+	modCalls := 0
+	client.OnUserState(func(channel string, state UserState) {
+		gotChans = append(gotChans, channel)
+	})
+	client.OnSelfModChange(func(channel string, mod bool) {
+		modCalls++
+		gotModChan, gotMod = channel, mod
+	})
+
+	line := createMessage("GLOBALUSERSTATE", "", nil, map[string]string{
+		"badges":       "premium/1",
+		"color":        "#FF0000",
+		"display-name": "TestBot",
+		"emote-sets":   "0,33,50",
+		"user-id":      "1337",
+	})
+	client.doCallbacks(line)
+
+	self := client.Self()
+	if self.UserID != "1337" || self.DisplayName != "TestBot" || self.Color != "#FF0000" {
+		t.Errorf("Unexpected state '%+v'", self)
+	}
+	if len(self.EmoteSets) != 3 {
+		t.Errorf("Expected '3' emote sets, got '%d'", len(self.EmoteSets))
+	}
+	if _, ok := client.SelfIn(expectedChan); ok {
+		t.Error("Expected 'false', got 'true'")
+	}
+
+	// Not a moderator
+	line = createMessage("USERSTATE", expectedChan, nil, map[string]string{
+		"badges":       "subscriber/6",
+		"display-name": "TestBot",
+		"mod":          "0",
+		"subscriber":   "1",
+	})
+	client.doCallbacks(line)
+
+	state, ok := client.SelfIn("test")
+	if !ok {
+		t.Error("Expected 'true', got 'false'")
+	}
+	if state.Mod || !state.Subscriber || state.UserID != "1337" {
+		t.Errorf("Unexpected state '%+v'", state)
+	}
+	if modCalls != 0 {
+		t.Errorf("Expected '0' calls, got '%d'", modCalls)
+	}
+
+	// Promoted to moderator
+	line = createMessage("USERSTATE", expectedChan, nil, map[string]string{
+		"badges": "moderator/1,subscriber/6",
+		"mod":    "1",
+	})
+	client.doCallbacks(line)
+
+	if state, _ := client.SelfIn(expectedChan); !state.Mod {
+		t.Error("Expected 'true', got 'false'")
+	}
+	if modCalls != 1 || gotModChan != expectedChan || !gotMod {
+		t.Errorf("Expected '1' call for '%s', got '%d' for '%s'", expectedChan, modCalls, gotModChan)
+	}
+
+	// Still a moderator
+	client.doCallbacks(line)
+	if modCalls != 1 {
+		t.Errorf("Expected '1' call, got '%d'", modCalls)
+	}
+
+	// VIP after losing moderator status
+	line = createMessage("USERSTATE", expectedChan, nil, map[string]string{"badges": "vip/1", "mod": "0"})
+	client.doCallbacks(line)
+
+	if state, _ := client.SelfIn(expectedChan); state.Mod || !state.VIP {
+		t.Errorf("Unexpected state '%+v'", state)
+	}
+	if modCalls != 2 || gotMod {
+		t.Errorf("Expected '2' calls, got '%d'", modCalls)
+	}
+
+	expectedChans := []string{"", expectedChan, expectedChan, expectedChan, expectedChan}
+	if len(gotChans) != len(expectedChans) {
+		t.Fatalf("Expected '%v', got '%v'", expectedChans, gotChans)
+	}
+	for i := range expectedChans {
+		if gotChans[i] != expectedChans[i] {
+			t.Errorf("Expected '%s', got '%s'", expectedChans[i], gotChans[i])
+		}
+	}
+}
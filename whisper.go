@@ -0,0 +1,43 @@
+package gotirc
+
+// WhisperEvent is a whisper received by the client
+type WhisperEvent struct {
+	From        string            // Login name of the sender
+	DisplayName string            // Display name of the sender
+	UserID      string            // User ID of the sender
+	To          string            // Login name of the recipient
+	MessageID   string            // ID of the whisper
+	ThreadID    string            // ID of the conversation, e.g., "1234_5678"
+	Color       string            // Chat color of the sender, e.g., "#1E90FF"
+	Badges      map[string]string // Badges of the sender, by name
+	Message     string
+	Tags        map[string]string // All tags of the WHISPER message
+}
+
+// OnWhisperEvent adds an event callback for when a user whispers the client,
+// which receives the whisper as a WhisperEvent
+func (c *Client) OnWhisperEvent(callback func(whisper WhisperEvent)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.whisperEventCallbacks = append(c.whisperEventCallbacks, callback)
+}
+
+func newWhisperEvent(msg *Message) WhisperEvent {
+	w := WhisperEvent{
+		From:        msg.Prefix.Nick,
+		DisplayName: msg.Tags["display-name"],
+		UserID:      msg.Tags["user-id"],
+		MessageID:   msg.Tags["message-id"],
+		ThreadID:    msg.Tags["thread-id"],
+		Color:       msg.Tags["color"],
+		Badges:      parseBadges(msg.Tags["badges"]),
+		Tags:        msg.Tags,
+	}
+	if len(msg.Params) > 0 {
+		w.To = msg.Params[0]
+	}
+	if len(msg.Params) > 1 {
+		w.Message = msg.Params[1]
+	}
+	return w
+}